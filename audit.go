@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrAuditDisabled is returned by Audit if Config.AuditOutput is not set
+var ErrAuditDisabled = errors.New("audit output is not configured")
+
+// auditHashKey is the key of the hash in an audit record. It is always the
+// last key so that the hashed bytes can be recovered from the record.
+const auditHashKey = `,"hash":"`
+
+// maxAuditRecord bounds the size of a record read back from an audit log
+const maxAuditRecord = 1 << 20
+
+// auditRecord is an audit log line without its hash. Fields are encoded in
+// declaration order, so the encoding is the same when verifying.
+type auditRecord struct {
+	Seq      uint64                 `json:"seq"`
+	Time     time.Time              `json:"time"`
+	Action   string                 `json:"action"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	PrevHash string                 `json:"prev_hash"`
+}
+
+// auditLog writes hash-chained records to a dedicated output
+type auditLog struct {
+	path        string
+	key         []byte
+	sink        zapcore.WriteSyncer
+	closeSink   func()
+	errorOutput zapcore.WriteSyncer
+
+	mu       sync.Mutex
+	seq      uint64
+	prevHash string
+
+	// syncMu orders syncs with closing the output
+	syncMu sync.Mutex
+	closed bool
+	// closeErr is the error syncing the output when it was closed
+	closeErr error
+}
+
+// newAuditLog opens an audit output. If it is an existing file, the chain
+// continues from its last record.
+func newAuditLog(path string, key []byte) (*auditLog, error) {
+	a := &auditLog{path: path, key: key}
+	if last, err := readLastAuditRecord(path); err != nil {
+		return nil, err
+	} else if last != nil {
+		a.seq, a.prevHash = last.seq, last.hash
+	}
+
+	sink, closeSink, err := zap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	a.sink, a.closeSink = sink, closeSink
+	return a, nil
+}
+
+// Audit writes a record of action to Config.AuditOutput. Audit records are
+// never sampled or level filtered. Each record has a sequence number and a
+// SHA-256 hash, or HMAC-SHA256 if Config.AuditKey is set, over its content
+// and the hash of the previous record, so that VerifyAuditLog can detect
+// missing, reordered and modified records. Trace correlation fields and the
+// request ID from ctx are added to fields.
+//
+// The record is synced to the output before Audit returns. Failures are
+// returned and also reported as internal errors.
+func Audit(ctx context.Context, action string, fields ...zap.Field) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		fields = append([]zap.Field{zap.String(RequestIDKey, requestID)}, fields...)
+	}
+	fields = append(TraceFields(ctx), fields...)
+
+	// The record is written while mu is held so that Initialize cannot
+	// continue the chain in a new auditLog before it is written, but the
+	// output is synced afterwards so that a slow disk does not block it
+	mu.RLock()
+	a := audit
+	if a == nil {
+		mu.RUnlock()
+		return ErrAuditDisabled
+	}
+	errorOutput := a.errorOutput
+	err := a.write(action, fields)
+	mu.RUnlock()
+	if err == nil {
+		err = a.sync()
+	}
+
+	if err != nil {
+		err = fmt.Errorf("audit: %w", err)
+		fmt.Fprintf(errorOutput, "%v %v\n", time.Now().UTC(), err)
+		_ = errorOutput.Sync()
+		return err
+	}
+	return nil
+}
+
+// write appends a record to the chain
+func (a *auditLog) write(action string, fields []zap.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	record := auditRecord{
+		Seq:      a.seq + 1,
+		Time:     time.Now().UTC(),
+		Action:   action,
+		Fields:   enc.Fields,
+		PrevHash: a.prevHash,
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sum := auditHash(a.key, body)
+
+	line := make([]byte, 0, len(body)+len(auditHashKey)+len(sum)+3)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, auditHashKey...)
+	line = append(line, sum...)
+	line = append(line, "\"}\n"...)
+	if _, err := a.sink.Write(line); err != nil {
+		return err
+	}
+
+	a.seq, a.prevHash = record.Seq, sum
+	return nil
+}
+
+// sync syncs the output. If it has been closed meanwhile, close synced it
+// and its result is returned.
+func (a *auditLog) sync() error {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	if a.closed {
+		return a.closeErr
+	}
+	return syncAuditSink(a.sink)
+}
+
+// close syncs and closes the output
+func (a *auditLog) close() {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	if a.closed {
+		return
+	}
+	a.closeErr = syncAuditSink(a.sink)
+	a.closeSink()
+	a.closed = true
+}
+
+// syncAuditSink syncs sink, ignoring the error of outputs such as
+// terminals that cannot be synced
+func syncAuditSink(sink zapcore.WriteSyncer) error {
+	if err := sink.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// auditHash returns the hex SHA-256 of body, keyed with HMAC if key is set
+func auditHash(key, body []byte) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditError describes the first problem found by VerifyAuditLog
+type AuditError struct {
+	// Line is the 1-based line number of the offending record
+	Line int
+	// Seq is the sequence number of the offending record, if it could be
+	// parsed
+	Seq    uint64
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("audit log line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// VerifyAuditLog checks the hash chain of an audit log written by Audit,
+// using the same key as Config.AuditKey, or nil. It returns an *AuditError
+// for the first record that was modified, is out of order, or follows a
+// gap. The first record may have any sequence number so that rotated logs
+// can be verified, which also means that records removed from the start of
+// a log cannot be detected.
+func VerifyAuditLog(r io.Reader, key []byte) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxAuditRecord)
+
+	var prev *parsedAuditRecord
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record, err := parseAuditRecord(scanner.Bytes())
+		if err != nil {
+			return &AuditError{Line: line, Reason: err.Error()}
+		}
+		if !hmac.Equal([]byte(auditHash(key, record.body)), []byte(record.hash)) {
+			return &AuditError{Line: line, Seq: record.seq, Reason: "hash mismatch, record was modified"}
+		}
+		if prev != nil {
+			switch {
+			case record.seq <= prev.seq:
+				return &AuditError{Line: line, Seq: record.seq,
+					Reason: fmt.Sprintf("out of order after seq %d", prev.seq)}
+			case record.seq != prev.seq+1:
+				return &AuditError{Line: line, Seq: record.seq,
+					Reason: fmt.Sprintf("gap after seq %d", prev.seq)}
+			case record.prevHash != prev.hash:
+				return &AuditError{Line: line, Seq: record.seq, Reason: "previous hash does not match"}
+			}
+		}
+		prev = record
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("audit: reading log: %w", err)
+	}
+	return nil
+}
+
+// parsedAuditRecord is an audit record read back from a log
+type parsedAuditRecord struct {
+	seq      uint64
+	prevHash string
+	hash     string
+	body     []byte
+}
+
+// parseAuditRecord splits a record into the hashed body and its hash
+func parseAuditRecord(line []byte) (*parsedAuditRecord, error) {
+	line = bytes.TrimSpace(line)
+	i := bytes.LastIndex(line, []byte(auditHashKey))
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, errors.New("malformed record: missing hash")
+	}
+	sum := line[i+len(auditHashKey) : len(line)-2]
+	body := append(append([]byte(nil), line[:i]...), '}')
+
+	var record auditRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return nil, fmt.Errorf("malformed record: %w", err)
+	}
+	return &parsedAuditRecord{
+		seq:      record.Seq,
+		prevHash: record.PrevHash,
+		hash:     string(sum),
+		body:     body,
+	}, nil
+}
+
+// readLastAuditRecord returns the last record of an existing audit log
+// file, or nil if path is not a regular file or has no records
+func readLastAuditRecord(path string) (*parsedAuditRecord, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+
+	offset := info.Size() - maxAuditRecord
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	lines := bytes.Split(bytes.TrimRight(tail, "\n"), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(bytes.TrimSpace(last)) == 0 {
+		return nil, nil
+	}
+	record, err := parseAuditRecord(last)
+	if err != nil {
+		return nil, fmt.Errorf("audit: cannot continue %s: %w", path, err)
+	}
+	return record, nil
+}
@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// writeAuditLog initializes the logger with an audit output and returns a
+// function reading it back
+func writeAuditLog(t *testing.T, key []byte) (string, func() []string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	config := DefaultConfig(Production)
+	config.AuditOutput = path
+	config.AuditKey = key
+	initializeToFile(t, config)
+
+	return path, func() []string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading audit log: %v", err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	_, read := writeAuditLog(t, nil)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+	for _, action := range []string{"user.login", "user.update", "user.logout"} {
+		if err := Audit(ctx, action, zap.String("user", "alice")); err != nil {
+			t.Fatalf("Audit(%q) error = %v", action, err)
+		}
+	}
+
+	lines := read()
+	if len(lines) != 3 {
+		t.Fatalf("got %d records, want 3", len(lines))
+	}
+	entries := decodeEntries(t, lines)
+	for i, entry := range entries {
+		if entry["seq"] != float64(i+1) {
+			t.Errorf("record %d seq = %v", i, entry["seq"])
+		}
+		fields, _ := entry["fields"].(map[string]interface{})
+		if fields["user"] != "alice" || fields[RequestIDKey] != "req-1" {
+			t.Errorf("record %d fields = %v", i, entry["fields"])
+		}
+	}
+	if entries[0]["prev_hash"] != "" || entries[1]["prev_hash"] != entries[0]["hash"] {
+		t.Errorf("records are not chained: %v", entries)
+	}
+
+	if err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "\n")), nil); err != nil {
+		t.Errorf("VerifyAuditLog() error = %v, want nil", err)
+	}
+}
+
+func TestAuditNotSampled(t *testing.T) {
+	_, read := writeAuditLog(t, nil)
+
+	// Production samples after 100 entries per second and drops Debug
+	for i := 0; i < 150; i++ {
+		if err := Audit(context.Background(), "read"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := read(); len(lines) != 150 {
+		t.Errorf("got %d records, want 150", len(lines))
+	}
+}
+
+func TestVerifyAuditLogDetectsTampering(t *testing.T) {
+	_, read := writeAuditLog(t, nil)
+	for _, action := range []string{"a", "b", "c", "d"} {
+		if err := Audit(context.Background(), action, zap.Int("amount", 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := read()
+
+	tests := []struct {
+		name     string
+		lines    []string
+		wantLine int
+		reason   string
+	}{
+		{"modified", []string{lines[0], strings.Replace(lines[1], `"amount":10`, `"amount":1000`, 1), lines[2]}, 2, "modified"},
+		{"gap", []string{lines[0], lines[2], lines[3]}, 2, "gap"},
+		{"reordered", []string{lines[0], lines[2], lines[1], lines[3]}, 2, "gap"},
+		{"replayed", []string{lines[0], lines[1], lines[1]}, 3, "out of order"},
+		{"truncated", []string{lines[0], lines[1][:40]}, 2, "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAuditLog(strings.NewReader(strings.Join(tt.lines, "\n")), nil)
+			var auditErr *AuditError
+			if !errors.As(err, &auditErr) {
+				t.Fatalf("VerifyAuditLog() error = %v, want *AuditError", err)
+			}
+			if auditErr.Line != tt.wantLine || !strings.Contains(auditErr.Reason, tt.reason) {
+				t.Errorf("VerifyAuditLog() error = %v, want line %d %q", err, tt.wantLine, tt.reason)
+			}
+		})
+	}
+
+	// A suffix of the log, e.g. after rotation, verifies
+	if err := VerifyAuditLog(strings.NewReader(strings.Join(lines[2:], "\n")), nil); err != nil {
+		t.Errorf("VerifyAuditLog(suffix) error = %v, want nil", err)
+	}
+}
+
+func TestAuditKey(t *testing.T) {
+	key := []byte("secret")
+	path, _ := writeAuditLog(t, key)
+	if err := Audit(context.Background(), "user.delete"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyAuditLog(bytes.NewReader(data), key); err != nil {
+		t.Errorf("VerifyAuditLog(key) error = %v, want nil", err)
+	}
+	if err := VerifyAuditLog(bytes.NewReader(data), nil); err == nil {
+		t.Error("VerifyAuditLog(nil) error = nil, want a hash mismatch")
+	}
+	if err := VerifyAuditLog(bytes.NewReader(data), []byte("other")); err == nil {
+		t.Error("VerifyAuditLog(other key) error = nil, want a hash mismatch")
+	}
+}
+
+func TestAuditContinuesChain(t *testing.T) {
+	path, read := writeAuditLog(t, nil)
+	if err := Audit(context.Background(), "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening the file again, e.g. after a restart, continues the chain
+	Initialize(DefaultConfig(Test))
+	config := DefaultConfig(Production)
+	config.AuditOutput = path
+	if err := Initialize(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := Audit(context.Background(), "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := read()
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2", len(lines))
+	}
+	if err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "\n")), nil); err != nil {
+		t.Errorf("VerifyAuditLog() error = %v, want nil", err)
+	}
+}
+
+// blockingSyncer blocks Sync until release is closed
+type blockingSyncer struct {
+	zapcore.WriteSyncer
+	syncing chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSyncer) Sync() error {
+	select {
+	case s.syncing <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.WriteSyncer.Sync()
+}
+
+func TestAuditSyncDoesNotBlockInitialize(t *testing.T) {
+	_, read := writeAuditLog(t, nil)
+	sink := &blockingSyncer{syncing: make(chan struct{}, 1), release: make(chan struct{})}
+	mu.Lock()
+	sink.WriteSyncer = audit.sink
+	audit.sink = sink
+	mu.Unlock()
+
+	done := make(chan error)
+	go func() { done <- Audit(context.Background(), "user.deleted") }()
+	<-sink.syncing
+
+	initialized := make(chan struct{})
+	go func() {
+		Initialize(DefaultConfig(Test))
+		close(initialized)
+	}()
+	select {
+	case <-initialized:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Initialize blocked by an audit sync")
+	}
+
+	close(sink.release)
+	if err := <-done; err != nil {
+		t.Errorf("Audit() error = %v", err)
+	}
+	if lines := read(); len(lines) != 1 || !strings.Contains(lines[0], `"user.deleted"`) {
+		t.Errorf("audit log = %q", lines)
+	}
+}
+
+func TestAuditDisabled(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Test))
+	if err := Audit(context.Background(), "action"); !errors.Is(err, ErrAuditDisabled) {
+		t.Errorf("Audit() error = %v, want ErrAuditDisabled", err)
+	}
+}
@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CallerMode selects how the caller of an entry is reported
+type CallerMode int
+
+const (
+	// CallerShort reports the package directory, file and line, e.g.
+	// "api/handler.go:42"
+	CallerShort CallerMode = iota
+	// CallerOff does not report the caller
+	CallerOff
+	// CallerFull reports the full path of the file and the line
+	CallerFull
+	// CallerFunction reports the caller like CallerShort and the name of
+	// the calling function under FunctionKey
+	CallerFunction
+)
+
+// FunctionKey is the key of the calling function with CallerFunction
+const FunctionKey = "function"
+
+// String returns the name of the caller mode
+func (m CallerMode) String() string {
+	switch m {
+	case CallerShort:
+		return "short"
+	case CallerOff:
+		return "off"
+	case CallerFull:
+		return "full"
+	case CallerFunction:
+		return "function"
+	default:
+		return "unknown"
+	}
+}
+
+// apply configures zapConfig to report callers as m
+func (m CallerMode) apply(zapConfig *zap.Config) {
+	switch m {
+	case CallerOff:
+		zapConfig.DisableCaller = true
+	case CallerFull:
+		zapConfig.EncoderConfig.EncodeCaller = zapcore.FullCallerEncoder
+	case CallerFunction:
+		zapConfig.EncoderConfig.FunctionKey = FunctionKey
+	}
+}
+
+// format formats caller for the pretty encoding
+func (m CallerMode) format(caller zapcore.EntryCaller) string {
+	switch m {
+	case CallerFull:
+		return caller.File + ":" + strconv.Itoa(caller.Line)
+	case CallerFunction:
+		return shortFunction(caller.Function) + " " + caller.TrimmedPath()
+	default:
+		return caller.TrimmedPath()
+	}
+}
+
+// shortFunction strips the package path from a function name, e.g.
+// "github.com/org/app/api.(*Server).handle" becomes "api.(*Server).handle"
+func shortFunction(function string) string {
+	return function[strings.LastIndexByte(function, '/')+1:]
+}
+
+var (
+	// helpers holds the names of functions marked with Helper
+	helpers sync.Map
+	// hasHelpers is set once a function has been marked, so that callers
+	// are looked up without the map until then
+	hasHelpers atomic.Bool
+)
+
+// Helper marks the calling function as a logging helper, like
+// testing.T.Helper. Entries logged by the package-level functions from a
+// helper report the helper's caller instead, so that a wrapper such as
+//
+//	func logRequest(r *http.Request) {
+//		logger.Helper()
+//		logger.Info("request", zap.String("path", r.URL.Path))
+//	}
+//
+// reports the line that called logRequest. Helper affects the
+// package-level functions, including the Context functions, but not
+// loggers returned by GetLogger or With.
+func Helper() {
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	if _, loaded := helpers.LoadOrStore(frame.Function, struct{}{}); !loaded {
+		hasHelpers.Store(true)
+	}
+}
+
+// isHelper reports whether function has been marked with Helper
+func isHelper(function string) bool {
+	if !hasHelpers.Load() {
+		return false
+	}
+	_, ok := helpers.Load(function)
+	return ok
+}
+
+// checkSkip is the number of frames above the caller of a package-level
+// function: runtime.Callers, entryCaller, check, the unexported logging
+// function and the exported one
+const checkSkip = 5
+
+// funcsCallerSkip makes the stack traces of the loggers used by check
+// start at the caller of the package-level function, skipping check, the
+// unexported logging function and the exported one
+const funcsCallerSkip = 3
+
+// funcsLogger returns a copy of l for check. It does not look up callers,
+// as check sets them itself.
+func funcsLogger(l *zap.Logger) *zap.Logger {
+	if l == nil {
+		return nil
+	}
+	return l.WithOptions(zap.WithCaller(false), zap.AddCallerSkip(funcsCallerSkip))
+}
+
+// check returns a checked entry for level and msg if it is enabled. If
+// caller is set, the entry reports the caller of the package-level
+// function, skipping functions marked with Helper. check must be called
+// by an unexported logging function that is called directly by the
+// exported one, so that every entry point has the same stack depth.
+func check(l *zap.Logger, caller bool, level zapcore.Level, msg string) *zapcore.CheckedEntry {
+	ce := l.Check(level, msg)
+	if ce != nil && caller {
+		ce.Caller = entryCaller(checkSkip)
+	}
+	return ce
+}
+
+// entryCaller returns the first frame, skip frames up, that is not in a
+// function marked with Helper
+func entryCaller(skip int) zapcore.EntryCaller {
+	var pcs [16]uintptr
+	n := runtime.Callers(skip, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !more || !isHelper(frame.Function) {
+			return zapcore.EntryCaller{
+				Defined:  frame.PC != 0,
+				PC:       frame.PC,
+				File:     frame.File,
+				Line:     frame.Line,
+				Function: frame.Function,
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// line returns the line it is called from
+func line() int {
+	_, _, n, _ := runtime.Caller(1)
+	return n
+}
+
+// logThrough is a wrapper marked as a logging helper
+func logThrough(msg string) {
+	Helper()
+	Info(msg)
+}
+
+// logThroughNested calls a helper from a helper
+func logThroughNested(msg string) {
+	Helper()
+	logThrough(msg)
+}
+
+// logUnmarked is a wrapper that is not marked as a helper
+func logUnmarked(msg string) int {
+	Info(msg)
+	return line() - 1
+}
+
+func TestCaller(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+	ctx := context.Background()
+
+	// Each call is followed by the line() recording it
+	want := map[string]int{}
+	Info("Info")
+	want["Info"] = line() - 1
+	Infof("%s", "Infof")
+	want["Infof"] = line() - 1
+	Infow("Infow", "key", "value")
+	want["Infow"] = line() - 1
+	Notice("Notice")
+	want["Notice"] = line() - 1
+	Noticef("%s", "Noticef")
+	want["Noticef"] = line() - 1
+	Criticalf("%s", "Criticalf")
+	want["Criticalf"] = line() - 1
+	DPanic("DPanic")
+	want["DPanic"] = line() - 1
+	InfoContext(ctx, "InfoContext")
+	want["InfoContext"] = line() - 1
+	InfoContext(NewContext(ctx, With()), "stored")
+	want["stored"] = line() - 1
+	With(zap.Int("n", 1)).Info("With")
+	want["With"] = line() - 1
+	GetSugar().Infow("GetSugar")
+	want["GetSugar"] = line() - 1
+	logThrough("helper")
+	want["helper"] = line() - 1
+	logThroughNested("nested")
+	want["nested"] = line() - 1
+	want["unmarked"] = logUnmarked("unmarked")
+
+	entries := decodeEntries(t, read())
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for _, entry := range entries {
+		msg, _ := entry["msg"].(string)
+		if suffix := fmt.Sprintf("/caller_test.go:%d", want[msg]); !strings.HasSuffix(fmt.Sprint(entry["caller"]), suffix) {
+			t.Errorf("%s: caller = %v, want ...%s", msg, entry["caller"], suffix)
+		}
+	}
+}
+
+func TestCallerKeyValueProblems(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	Infow("pairs", "key", "value", 1, "invalid", "dangling")
+	want := line() - 1
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	msgs := []string{"Ignored key without a value.", "Ignored key-value pairs with non-string keys.", "pairs"}
+	for i, entry := range entries {
+		if entry["msg"] != msgs[i] {
+			t.Errorf("entry %d msg = %v, want %q", i, entry["msg"], msgs[i])
+		}
+		if suffix := fmt.Sprintf("/caller_test.go:%d", want); !strings.HasSuffix(fmt.Sprint(entry["caller"]), suffix) {
+			t.Errorf("%v: caller = %v, want ...%s", entry["msg"], entry["caller"], suffix)
+		}
+	}
+	if entries[0]["ignored"] != "dangling" {
+		t.Errorf("ignored = %v", entries[0]["ignored"])
+	}
+	if entries[2]["key"] != "value" {
+		t.Errorf("key = %v", entries[2]["key"])
+	}
+}
+
+func TestCallerStacktrace(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	Error("failed")
+	ErrorContext(context.Background(), "failed")
+
+	for _, entry := range decodeEntries(t, read()) {
+		stack, _ := entry["stacktrace"].(string)
+		if !strings.HasPrefix(stack, "github.com/kingrain94/logger.TestCallerStacktrace\n") {
+			t.Errorf("stacktrace does not start at the test:\n%s", stack)
+		}
+	}
+}
+
+func TestCallerPanic(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	var want int
+	func() {
+		defer func() { recover() }()
+		want = line() + 1
+		Panicf("%s", "panicked")
+	}()
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if suffix := fmt.Sprintf("/caller_test.go:%d", want); !strings.HasSuffix(fmt.Sprint(entries[0]["caller"]), suffix) {
+		t.Errorf("caller = %v, want ...%s", entries[0]["caller"], suffix)
+	}
+}
+
+func TestCallerMode(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+
+	tests := []struct {
+		mode     CallerMode
+		caller   func(string) bool
+		function string
+	}{
+		{CallerShort, func(c string) bool { return strings.HasPrefix(c, filepath.Base(filepath.Dir(file))+"/caller_test.go:") }, ""},
+		{CallerOff, func(c string) bool { return c == "" }, ""},
+		{CallerFull, func(c string) bool { return strings.HasPrefix(c, file+":") }, ""},
+		{CallerFunction, func(c string) bool { return strings.Contains(c, "/caller_test.go:") }, "github.com/kingrain94/logger.TestCallerMode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			config := DefaultConfig(Staging)
+			config.Caller = tt.mode
+			read := initializeToFile(t, config)
+
+			Info("entry")
+			With().Info("entry")
+
+			for _, entry := range decodeEntries(t, read()) {
+				caller, _ := entry["caller"].(string)
+				if !tt.caller(caller) {
+					t.Errorf("caller = %q", caller)
+				}
+				function, _ := entry[FunctionKey].(string)
+				if tt.function != "" && !strings.HasPrefix(function, tt.function) || tt.function == "" && function != "" {
+					t.Errorf("function = %q, want %q", function, tt.function)
+				}
+			}
+		})
+	}
+}
+
+func TestCallerModePretty(t *testing.T) {
+	config := DefaultConfig(Development)
+	config.Encoding = "pretty"
+	config.Pretty = &PrettyConfig{Color: ColorNever}
+	config.Caller = CallerFunction
+	read := initializeToFile(t, config)
+
+	Info("entry")
+
+	lines := read()
+	if len(lines) == 0 || !strings.Contains(lines[0], "logger.TestCallerModePretty ") {
+		t.Errorf("pretty output does not show the function: %q", lines)
+	}
+}
+
+func BenchmarkInfoCaller(b *testing.B) {
+	config := DefaultConfig(Staging)
+	config.OutputPaths = []string{filepath.Join(b.TempDir(), "test.log")}
+	Initialize(config)
+	defer Initialize(DefaultConfig(Test))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Info("benchmark", zap.Int("iteration", i))
+	}
+}
@@ -0,0 +1,17 @@
+// Command fmtcheck reports non-constant format strings passed to the
+// logger's Debugf, Infof, Warnf, Errorf, Fatalf and other f functions.
+//
+// It can be run directly or as a vet tool:
+//
+//	go install github.com/kingrain94/logger/cmd/fmtcheck@latest
+//	go vet -vettool=$(which fmtcheck) ./...
+package main
+
+import (
+	"github.com/kingrain94/logger/fmtcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(fmtcheck.Analyzer)
+}
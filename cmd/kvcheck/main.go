@@ -0,0 +1,17 @@
+// Command kvcheck reports malformed key-value arguments passed to the
+// logger's Debugw, Infow, Warnw, Errorw, Fatalw and Panicw functions.
+//
+// It can be run directly or as a vet tool:
+//
+//	go install github.com/kingrain94/logger/cmd/kvcheck@latest
+//	go vet -vettool=$(which kvcheck) ./...
+package main
+
+import (
+	"github.com/kingrain94/logger/kvcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(kvcheck.Analyzer)
+}
@@ -0,0 +1,155 @@
+// Command logquery aggregates JSON logs written by the logger's Staging
+// and Production configs.
+//
+//	logquery query [flags] [file ...]
+//
+// It reads the files, or stdin if there are none or a file is "-".
+// Gzip-compressed files, such as rotated logs, are decompressed as they are
+// read; lines that are not JSON objects are skipped. The queries are:
+//
+//	count        entries grouped by level or fields: -by level,service
+//	top          the most frequent messages: -n 10
+//	percentiles  percentiles of a duration field: -field duration -p 50,90,99
+//	errors       error rate per time bucket: -bucket 1m
+//
+// Every query accepts the filter flags of logview (-level, -since, -until,
+// -msg and -where) and prints a table, CSV or JSON with -format:
+//
+//	logquery count -by level /var/log/app.log /var/log/app.log.*.gz
+//	logquery percentiles -field duration -by route -since 1h app.log
+//	logquery errors -bucket 5m -format csv app.log > errors.csv
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kingrain94/logger/internal/logfile"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+const usage = `usage: logquery query [flags] [file ...]
+
+queries:
+  count        count entries grouped by level or fields
+  top          list the most frequent messages
+  percentiles  compute percentiles of a duration field
+  errors       compute the error rate per time bucket
+
+Run "logquery query -h" for the flags of a query.
+`
+
+// run runs logquery and returns its exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	name := args[0]
+	flags := flag.NewFlagSet("logquery "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var filterFlags logfile.FilterFlags
+	filterFlags.Register(flags)
+	format := flags.String("format", "table", "output `format`: table, csv or json")
+
+	var newQuery func() (query, error)
+	switch name {
+	case "count":
+		by := flags.String("by", logfile.LevelKey, "comma-separated `keys` to group by")
+		n := flags.Int("n", 0, "show the `n` largest groups, or all if 0")
+		newQuery = func() (query, error) { return newCountQuery(splitKeys(*by), *n) }
+	case "top":
+		n := flags.Int("n", 10, "show the `n` most frequent messages")
+		newQuery = func() (query, error) { return newCountQuery([]string{logfile.MsgKey}, *n) }
+	case "percentiles":
+		field := flags.String("field", "duration", "duration `key`, as a number of seconds or a string such as 1.5s")
+		percents := flags.String("p", "50,90,95,99", "comma-separated `percentiles`")
+		by := flags.String("by", "", "comma-separated `keys` to group by")
+		newQuery = func() (query, error) { return newPercentilesQuery(*field, *percents, splitKeys(*by)) }
+	case "errors":
+		bucket := flags.Duration("bucket", time.Minute, "`duration` of the time buckets")
+		newQuery = func() (query, error) { return newErrorsQuery(*bucket) }
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "logquery: unknown query %q\n%s", name, usage)
+		return 2
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	filter, err := filterFlags.Filter(time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "logquery: %v\n", err)
+		return 2
+	}
+	write, ok := writers[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "logquery: invalid -format %q: want table, csv or json\n", *format)
+		return 2
+	}
+	q, err := newQuery()
+	if err != nil {
+		fmt.Fprintf(stderr, "logquery: %v\n", err)
+		return 2
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		if err := scan(file, stdin, filter, q); err != nil {
+			fmt.Fprintf(stderr, "logquery: %v\n", err)
+			return 1
+		}
+	}
+	if err := write(stdout, q.result()); err != nil {
+		fmt.Fprintf(stderr, "logquery: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// scan adds the records of a file that pass the filter to q
+func scan(name string, stdin io.Reader, filter *logfile.Filter, q query) error {
+	r, err := logfile.Open(name, stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	err = logfile.Lines(r, func(line []byte) error {
+		if rec, ok := logfile.Parse(line); ok && filter.Match(rec) {
+			q.add(rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	return nil
+}
+
+// splitKeys splits a comma-separated list of keys
+func splitKeys(s string) []string {
+	var keys []string
+	for _, key := range strings.Split(s, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLogs writes a plain current log and a gzip-compressed rotated log
+func writeLogs(t *testing.T) []string {
+	t.Helper()
+	dir := t.TempDir()
+	rotated := `{"level":"info","ts":1700000000,"msg":"request served","route":"/users","duration":0.1}
+{"level":"info","ts":1700000010,"msg":"request served","route":"/users","duration":0.2}
+{"level":"error","ts":1700000050,"msg":"payment failed","route":"/pay","duration":"1.5s"}
+`
+	current := `{"level":"info","ts":1700000070,"msg":"request served","route":"/pay","duration":0.3}
+panic: something went wrong
+{"level":"warn","ts":1700000080,"msg":"slow request","route":"/users","duration":2}
+{"level":"error","ts":1700000090,"msg":"payment failed","route":"/pay"}
+`
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(rotated))
+	zw.Close()
+
+	paths := []string{filepath.Join(dir, "app.log.1.gz"), filepath.Join(dir, "app.log")}
+	if err := os.WriteFile(paths[0], compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(paths[1], []byte(current), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func runQuery(t *testing.T, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	if code := run(args, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Fatalf("run(%q) = %d, stderr: %s", args, code, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestCount(t *testing.T) {
+	files := writeLogs(t)
+	got := runQuery(t, append([]string{"count", "-format", "csv"}, files...)...)
+	want := "level,count\ninfo,3\nerror,2\nwarn,1\n"
+	if got != want {
+		t.Errorf("count by level = %q, want %q", got, want)
+	}
+
+	got = runQuery(t, append([]string{"count", "-by", "level,route", "-n", "2", "-format", "csv"}, files...)...)
+	want = "level,route,count\nerror,/pay,2\ninfo,/users,2\n"
+	if got != want {
+		t.Errorf("count by level,route = %q, want %q", got, want)
+	}
+}
+
+func TestTop(t *testing.T) {
+	files := writeLogs(t)
+	got := runQuery(t, append([]string{"top", "-n", "2", "-where", "route=/pay"}, files...)...)
+	want := "MSG             COUNT\n" +
+		"payment failed  2\n" +
+		"request served  1\n"
+	if got != want {
+		t.Errorf("top = %q, want %q", got, want)
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	files := writeLogs(t)
+	got := runQuery(t, append([]string{"percentiles", "-by", "route", "-p", "50,100"}, files...)...)
+	want := "ROUTE   COUNT  P50    P100\n" +
+		"/pay    2      300ms  1.5s\n" +
+		"/users  3      200ms  2s\n"
+	if got != want {
+		t.Errorf("percentiles =\n%s\nwant\n%s", got, want)
+	}
+
+	var rows []map[string]interface{}
+	out := runQuery(t, append([]string{"percentiles", "-p", "90", "-format", "json"}, files...)...)
+	if err := json.Unmarshal([]byte(out), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["count"] != float64(5) || rows[0]["p90"] != float64(2) {
+		t.Errorf("percentiles JSON = %s", out)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	files := writeLogs(t)
+	got := runQuery(t, append([]string{"errors", "-bucket", "1m", "-format", "csv"}, files...)...)
+	start := time.Unix(1700000000, 0).UTC().Truncate(time.Minute)
+	want := "time,total,errors,error_rate\n" +
+		start.Format(time.RFC3339) + ",2,0,0.0000\n" +
+		start.Add(time.Minute).Format(time.RFC3339) + ",4,2,0.5000\n"
+	if got != want {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"sum"},
+		{"count", "-format", "xml"},
+		{"count", "-by", ""},
+		{"percentiles", "-p", "150"},
+		{"errors", "-bucket", "0s"},
+		{"top", "-where", "route"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, strings.NewReader(""), &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+	}
+}
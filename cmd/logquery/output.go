@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// writers write a table in each -format
+var writers = map[string]func(io.Writer, *table) error{
+	"table": writeTable,
+	"csv":   writeCSV,
+	"json":  writeJSON,
+}
+
+// writeTable writes aligned columns for reading. Durations are formatted
+// such as "1.5s".
+func writeTable(w io.Writer, t *table) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(t.columns, "\t")))
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			switch cell := cell.(type) {
+			case time.Duration:
+				cells[i] = cell.String()
+			case string:
+				if cell == "" {
+					cell = "-"
+				}
+				cells[i] = cell
+			default:
+				cells[i] = formatCell(cell)
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeCSV writes a header and a record per row. Durations are seconds,
+// as in the logs.
+func writeCSV(w io.Writer, t *table) error {
+	cw := csv.NewWriter(w)
+	cw.Write(t.columns)
+	for _, row := range t.rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = formatCell(cell)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON writes an array with an object per row. Durations are seconds,
+// as in the logs.
+func writeJSON(w io.Writer, t *table) error {
+	rows := make([]map[string]interface{}, 0, len(t.rows))
+	for _, row := range t.rows {
+		obj := make(map[string]interface{}, len(row))
+		for i, cell := range row {
+			switch cell := cell.(type) {
+			case time.Duration:
+				obj[t.columns[i]] = cell.Seconds()
+			default:
+				obj[t.columns[i]] = cell
+			}
+		}
+		rows = append(rows, obj)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// formatCell formats a cell for CSV, and the table cells without a
+// special format
+func formatCell(cell interface{}) string {
+	switch cell := cell.(type) {
+	case string:
+		return cell
+	case int:
+		return strconv.Itoa(cell)
+	case float64:
+		return strconv.FormatFloat(cell, 'f', 4, 64)
+	case time.Duration:
+		return strconv.FormatFloat(cell.Seconds(), 'f', -1, 64)
+	case time.Time:
+		return cell.Format(time.RFC3339)
+	}
+	return fmt.Sprint(cell)
+}
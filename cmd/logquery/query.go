@@ -0,0 +1,232 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kingrain94/logger/internal/filter"
+	"github.com/kingrain94/logger/internal/logfile"
+	"go.uber.org/zap/zapcore"
+)
+
+// query aggregates records
+type query interface {
+	add(r *logfile.Record)
+	result() *table
+}
+
+// table is the result of a query. Cells are strings, ints, float64s,
+// durations or times.
+type table struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// groupKey returns the values of keys in r, with "" for missing keys
+func groupKey(r *logfile.Record, keys []string) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		if v, ok := r.Lookup(key); ok {
+			values[i] = logfile.StringValue(v)
+		}
+	}
+	return values
+}
+
+// countQuery counts records grouped by keys
+type countQuery struct {
+	keys   []string
+	limit  int
+	counts map[string]*countGroup
+}
+
+type countGroup struct {
+	values []string
+	count  int
+}
+
+func newCountQuery(keys []string, limit int) (*countQuery, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("-by needs at least one key")
+	}
+	return &countQuery{keys: keys, limit: limit, counts: map[string]*countGroup{}}, nil
+}
+
+func (q *countQuery) add(r *logfile.Record) {
+	values := groupKey(r, q.keys)
+	id := strings.Join(values, "\x00")
+	g, ok := q.counts[id]
+	if !ok {
+		g = &countGroup{values: values}
+		q.counts[id] = g
+	}
+	g.count++
+}
+
+func (q *countQuery) result() *table {
+	groups := make([]*countGroup, 0, len(q.counts))
+	for _, g := range q.counts {
+		groups = append(groups, g)
+	}
+	// Largest groups first, ties by their values
+	slices.SortFunc(groups, func(a, b *countGroup) int {
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return slices.Compare(a.values, b.values)
+	})
+	if q.limit > 0 && len(groups) > q.limit {
+		groups = groups[:q.limit]
+	}
+
+	t := &table{columns: append(slices.Clone(q.keys), "count")}
+	for _, g := range groups {
+		row := make([]interface{}, 0, len(g.values)+1)
+		for _, v := range g.values {
+			row = append(row, v)
+		}
+		t.rows = append(t.rows, append(row, g.count))
+	}
+	return t
+}
+
+// percentilesQuery computes percentiles of a duration field, optionally
+// grouped by keys
+type percentilesQuery struct {
+	field    string
+	percents []float64
+	keys     []string
+	groups   map[string]*durationGroup
+}
+
+type durationGroup struct {
+	values    []string
+	durations []time.Duration
+}
+
+func newPercentilesQuery(field, percents string, keys []string) (*percentilesQuery, error) {
+	if field == "" {
+		return nil, errors.New("-field is required")
+	}
+	q := &percentilesQuery{field: field, keys: keys, groups: map[string]*durationGroup{}}
+	for _, s := range splitKeys(percents) {
+		p, err := strconv.ParseFloat(s, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q: want a number in (0, 100]", s)
+		}
+		q.percents = append(q.percents, p)
+	}
+	if len(q.percents) == 0 {
+		return nil, errors.New("-p needs at least one percentile")
+	}
+	return q, nil
+}
+
+func (q *percentilesQuery) add(r *logfile.Record) {
+	v, ok := r.Lookup(q.field)
+	if !ok {
+		return
+	}
+	d, ok := logfile.DurationValue(v)
+	if !ok {
+		return
+	}
+	values := groupKey(r, q.keys)
+	id := strings.Join(values, "\x00")
+	g, ok := q.groups[id]
+	if !ok {
+		g = &durationGroup{values: values}
+		q.groups[id] = g
+	}
+	g.durations = append(g.durations, d)
+}
+
+func (q *percentilesQuery) result() *table {
+	groups := make([]*durationGroup, 0, len(q.groups))
+	for _, g := range q.groups {
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b *durationGroup) int {
+		return slices.Compare(a.values, b.values)
+	})
+
+	t := &table{columns: append(slices.Clone(q.keys), "count")}
+	for _, p := range q.percents {
+		t.columns = append(t.columns, "p"+strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	for _, g := range groups {
+		slices.Sort(g.durations)
+		row := make([]interface{}, 0, len(t.columns))
+		for _, v := range g.values {
+			row = append(row, v)
+		}
+		row = append(row, len(g.durations))
+		for _, p := range q.percents {
+			row = append(row, percentile(g.durations, p))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// percentile returns the p-th percentile of sorted durations by the
+// nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// errorsQuery computes the error rate per time bucket. Entries at the
+// error level or above count as errors.
+type errorsQuery struct {
+	bucket  time.Duration
+	buckets map[time.Time]*errorBucket
+}
+
+type errorBucket struct {
+	total, errors int
+}
+
+func newErrorsQuery(bucket time.Duration) (*errorsQuery, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("invalid -bucket %s: must be positive", bucket)
+	}
+	return &errorsQuery{bucket: bucket, buckets: map[time.Time]*errorBucket{}}, nil
+}
+
+func (q *errorsQuery) add(r *logfile.Record) {
+	if r.Entry.Time.IsZero() {
+		return
+	}
+	start := r.Entry.Time.UTC().Truncate(q.bucket)
+	b, ok := q.buckets[start]
+	if !ok {
+		b = &errorBucket{}
+		q.buckets[start] = b
+	}
+	b.total++
+	if filter.LevelRank(r.Entry.Level) >= filter.LevelRank(zapcore.ErrorLevel) {
+		b.errors++
+	}
+}
+
+func (q *errorsQuery) result() *table {
+	starts := make([]time.Time, 0, len(q.buckets))
+	for start := range q.buckets {
+		starts = append(starts, start)
+	}
+	slices.SortFunc(starts, time.Time.Compare)
+
+	t := &table{columns: []string{"time", "total", "errors", "error_rate"}}
+	for _, start := range starts {
+		b := q.buckets[start]
+		t.rows = append(t.rows, []interface{}{start, b.total, b.errors, float64(b.errors) / float64(b.total)})
+	}
+	return t
+}
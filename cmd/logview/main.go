@@ -0,0 +1,273 @@
+// Command logview pretty-prints JSON logs written by the logger's Staging
+// and Production configs, using the layout of the "pretty" encoding.
+//
+//	logview [flags] [file ...]
+//
+// It reads the files, or stdin if there are none or a file is "-", and
+// decompresses gzip-compressed files. Lines that are not JSON objects are
+// passed through untouched. Entries can be filtered by level, time range,
+// message and fields:
+//
+//	logview -level warn -since 1h /var/log/app.log
+//	logview -msg 'payment|refund' -where user_id=42 -where 'duration>100ms' app.log
+//	kubectl logs -f deploy/api | logview -where 'http.status>=500'
+//
+// Field expressions compare a field, or a nested field such as
+// http.status, with =, !=, >, >=, < and <=, or match it against a regular
+// expression with ~. Values such as 100ms are compared as durations.
+//
+// With -f, files are followed like tail -f.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kingrain94/logger"
+	"github.com/kingrain94/logger/internal/logfile"
+	"go.uber.org/zap/zapcore"
+)
+
+// followInterval is how often followed files are checked for new lines
+const followInterval = 250 * time.Millisecond
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs logview and returns its exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("logview", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var (
+		filterFlags logfile.FilterFlags
+		follow      = flags.Bool("f", false, "follow files as they grow")
+		color       = flags.String("color", "auto", "use colors: auto, always or never")
+		timeLayout  = flags.String("time-layout", "2006-01-02 15:04:05.000", "Go time `layout` of timestamps")
+		order       = flags.String("order", "", "comma-separated `keys` of fields to show first")
+		width       = flags.Int("width", logger.DefaultPrettyWidth, "`column` the caller is aligned to")
+	)
+	filterFlags.Register(flags)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	f, err := filterFlags.Filter(time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "logview: %v\n", err)
+		return 2
+	}
+	pretty, err := prettyConfig(*color)
+	if err != nil {
+		fmt.Fprintf(stderr, "logview: %v\n", err)
+		return 2
+	}
+	pretty.TimeLayout = *timeLayout
+	pretty.Width = *width
+	if *order != "" {
+		pretty.FieldOrder = strings.Split(*order, ",")
+	}
+
+	v := &viewer{filter: f, enc: logger.NewPrettyEncoder(pretty), out: bufio.NewWriter(stdout)}
+	defer v.flush()
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	if *follow {
+		err = v.follow(ctx, files, stdin)
+	} else {
+		for _, name := range files {
+			if err = v.viewFile(name, stdin); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		v.flush()
+		fmt.Fprintf(stderr, "logview: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// prettyConfig returns the encoder configuration for the -color flag
+func prettyConfig(color string) (logger.PrettyConfig, error) {
+	var pretty logger.PrettyConfig
+	switch color {
+	case "auto":
+		pretty.Color = logger.ColorAuto
+	case "always":
+		pretty.Color = logger.ColorAlways
+	case "never":
+		pretty.Color = logger.ColorNever
+	default:
+		return pretty, fmt.Errorf("invalid -color %q: want auto, always or never", color)
+	}
+	return pretty, nil
+}
+
+// viewer renders lines to out
+type viewer struct {
+	filter *logfile.Filter
+	enc    zapcore.Encoder
+
+	mu  sync.Mutex
+	out *bufio.Writer
+}
+
+// line renders a JSON log line if it passes the filter, or passes through
+// a line that is not JSON
+func (v *viewer) line(line []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	r, ok := logfile.Parse(line)
+	if !ok {
+		if _, err := v.out.Write(line); err != nil {
+			return err
+		}
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			return v.out.WriteByte('\n')
+		}
+		return nil
+	}
+	if !v.filter.Match(r) {
+		return nil
+	}
+	buf, err := v.enc.EncodeEntry(r.Entry, r.Fields())
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+	_, err = v.out.Write(buf.Bytes())
+	return err
+}
+
+// flush writes buffered output
+func (v *viewer) flush() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.out.Flush()
+}
+
+// viewFile renders every line of a file, or of stdin for "-". Gzip
+// compressed files are decompressed.
+func (v *viewer) viewFile(name string, stdin io.Reader) error {
+	r, err := logfile.Open(name, stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := logfile.Lines(r, v.line); err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	return nil
+}
+
+// follow renders the lines of every file as they are written, until ctx
+// is done
+func (v *viewer) follow(ctx context.Context, files []string, stdin io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(files))
+	for _, name := range files {
+		go func(name string) {
+			if name == "-" {
+				errs <- v.viewFile(name, stdin)
+				return
+			}
+			errs <- v.followFile(ctx, name)
+		}(name)
+	}
+
+	var firstErr error
+	for range files {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	return firstErr
+}
+
+// followFile renders the lines of a file as they are written. If the file
+// is truncated, e.g. by log rotation with copytruncate, it is read again
+// from the start. If name is renamed or removed and created again, as by
+// lumberjack, the new file is read from the start once the old one has been
+// read to its end.
+func (v *viewer) followFile(ctx context.Context, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	br := bufio.NewReader(f)
+	var offset int64
+	var partial []byte
+	for {
+		line, err := br.ReadBytes('\n')
+		offset += int64(len(line))
+		if err == nil {
+			if err := v.line(append(partial, line...)); err != nil {
+				return err
+			}
+			partial = nil
+			continue
+		}
+		if err != io.EOF {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		// Keep an incomplete last line until the rest is written
+		partial = append(partial, line...)
+		v.flush()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followInterval):
+		}
+		info, err := f.Stat()
+		if err != nil {
+			continue
+		}
+		if info.Size() < offset {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			br.Reset(f)
+			offset, partial = 0, nil
+			continue
+		}
+		if info.Size() > offset {
+			continue
+		}
+		if current, err := os.Stat(name); err == nil && !os.SameFile(info, current) {
+			next, err := os.Open(name)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f = next
+			br.Reset(f)
+			offset, partial = 0, nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testLog = `{"level":"info","ts":1700000000.5,"caller":"api/handler.go:42","msg":"request served","user_id":42,"duration":0.25,"http":{"status":200}}
+{"level":"warn","ts":1700000060,"caller":"api/handler.go:42","msg":"slow request","user_id":7,"duration":"1.5s","http":{"status":503}}
+panic: something went wrong
+{"level":"error","ts":"2023-11-14T22:15:00Z","msg":"payment failed","user_id":42,"error":"card declined"}
+{not json
+`
+
+func view(t *testing.T, args ...string) []string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-color", "never", "-time-layout", "15:04:05"}, args...)
+	if code := run(context.Background(), args, strings.NewReader(testLog), &stdout, &stderr); code != 0 {
+		t.Fatalf("run(%q) = %d, stderr: %s", args, code, stderr.String())
+	}
+	return strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+}
+
+func TestRunPassesThroughNonJSONLines(t *testing.T) {
+	// Fields are rendered on indented lines below their entry
+	var lines []string
+	for _, line := range view(t) {
+		if !strings.HasPrefix(line, " ") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	if lines[2] != "panic: something went wrong" || lines[4] != "{not json" {
+		t.Errorf("non-JSON lines changed: %q, %q", lines[2], lines[4])
+	}
+	if !strings.Contains(lines[0], "INF") || !strings.Contains(lines[0], "request served") ||
+		!strings.Contains(lines[0], "api/handler.go:42") {
+		t.Errorf("line 0 = %q", lines[0])
+	}
+	if !strings.Contains(lines[3], "ERR") || !strings.Contains(lines[3], "payment failed") {
+		t.Errorf("line 3 = %q", lines[3])
+	}
+	if out := strings.Join(view(t), "\n"); !strings.Contains(out, "    user_id: 42\n    duration: 0.25") {
+		t.Errorf("fields not rendered in order:\n%s", out)
+	}
+}
+
+func TestRunFilters(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-level", "warn"}, []string{"slow request", "payment failed"}},
+		{[]string{"-msg", "^(slow|payment)"}, []string{"slow request", "payment failed"}},
+		{[]string{"-where", "user_id=42"}, []string{"request served", "payment failed"}},
+		{[]string{"-where", "duration>100ms", "-where", "duration<1s"}, []string{"request served"}},
+		{[]string{"-where", "http.status>=500"}, []string{"slow request"}},
+		{[]string{"-where", "error~declined"}, []string{"payment failed"}},
+		{[]string{"-where", "error!=card declined"}, []string{"request served", "slow request"}},
+		{[]string{"-since", "2023-11-14T22:14:00Z", "-until", "2023-11-14T22:14:30Z"}, []string{"slow request"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, line := range view(t, tt.args...) {
+			for _, msg := range []string{"request served", "slow request", "payment failed"} {
+				if strings.Contains(line, msg) {
+					got = append(got, msg)
+				}
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRunInvalidFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-level", "loud"},
+		{"-where", "user_id"},
+		{"-where", "=42"},
+		{"-where", "msg~("},
+		{"-since", "yesterday"},
+		{"-color", "sometimes"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr); code != 2 {
+			t.Errorf("run(%q) = %d, want 2", args, code)
+		}
+	}
+}
+
+func TestFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(`{"level":"info","msg":"first"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() {
+		done <- run(ctx, []string{"-f", "-color", "never", path}, strings.NewReader(""), out, out)
+	}()
+
+	waitFor(t, out, "first")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"level":"info","msg":"sec`)
+	f.Sync()
+	time.Sleep(2 * followInterval)
+	f.WriteString(`ond"}` + "\n")
+	f.Close()
+	waitFor(t, out, "second")
+
+	// Truncation, as by copytruncate rotation, restarts from the beginning
+	if err := os.WriteFile(path, []byte(`{"level":"info","msg":"third"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, out, "third")
+
+	// Rotation by rename, as by lumberjack, finishes the old file and then
+	// follows the new one
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	f, err = os.OpenFile(path+".1", os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"level":"info","msg":"fourth"}` + "\n")
+	f.Close()
+	if err := os.WriteFile(path, []byte(`{"level":"info","msg":"fifth"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, out, "fifth")
+	if !strings.Contains(out.String(), "fourth") {
+		t.Errorf("lines written before rotation were skipped: %s", out.String())
+	}
+	f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"level":"info","msg":"sixth"}` + "\n")
+	f.Close()
+	waitFor(t, out, "sixth")
+
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("run = %d, output: %s", code, out.String())
+	}
+	if strings.Contains(out.String(), `"msg"`) {
+		t.Errorf("partial line passed through: %s", out.String())
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitFor(t *testing.T, out *syncBuffer, s string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), s) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q, output: %s", s, out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
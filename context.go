@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Field names used for trace correlation
+const (
+	TraceIDKey    = "trace_id"
+	SpanIDKey     = "span_id"
+	TraceFlagsKey = "trace_flags"
+)
+
+// contextKey is the context key for the loggers stored with NewContext
+type contextKey struct{}
+
+// contextLoggers is the value stored by NewContext: the logger and its copy
+// for the Context functions, see funcsLogger
+type contextLoggers struct {
+	logger *zap.Logger
+	funcs  *zap.Logger
+}
+
+// NewContext returns a copy of ctx carrying l. Loggers stored in a context
+// should not include trace fields; they are added from the active span each
+// time an entry is logged.
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &contextLoggers{logger: l, funcs: funcsLogger(l)})
+}
+
+// FromContext returns the logger stored in ctx by NewContext, or the global
+// logger, with trace correlation fields for the active span in ctx
+func FromContext(ctx context.Context) *zap.Logger {
+	l := contextLogger(ctx)
+	if l == nil {
+		return nil
+	}
+	if fields := TraceFields(ctx); len(fields) > 0 {
+		return l.With(fields...)
+	}
+	return l
+}
+
+// TraceFields returns trace_id, span_id and trace_flags fields for the
+// active OpenTelemetry span in ctx, or nil if there is none
+func TraceFields(ctx context.Context) []zap.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String(TraceIDKey, sc.TraceID().String()),
+		zap.String(SpanIDKey, sc.SpanID().String()),
+		zap.String(TraceFlagsKey, sc.TraceFlags().String()),
+	}
+}
+
+// TraceContext logs a message at trace level with trace correlation fields
+// from ctx
+func TraceContext(ctx context.Context, msg string, fields ...zap.Field) {
+	logContext(ctx, TraceLevel, msg, fields, true)
+}
+
+// DebugContext logs a message at debug level with trace correlation fields
+// from ctx
+func DebugContext(ctx context.Context, msg string, fields ...zap.Field) {
+	logContext(ctx, zapcore.DebugLevel, msg, fields, true)
+}
+
+// InfoContext logs a message at info level with trace correlation fields
+// from ctx
+func InfoContext(ctx context.Context, msg string, fields ...zap.Field) {
+	logContext(ctx, zapcore.InfoLevel, msg, fields, true)
+}
+
+// NoticeContext logs a message at notice level with trace correlation
+// fields from ctx
+func NoticeContext(ctx context.Context, msg string, fields ...zap.Field) {
+	logContext(ctx, NoticeLevel, msg, fields, true)
+}
+
+// WarnContext logs a message at warn level with trace correlation fields
+// from ctx
+func WarnContext(ctx context.Context, msg string, fields ...zap.Field) {
+	logContext(ctx, zapcore.WarnLevel, msg, fields, true)
+}
+
+// ErrorContext logs a message at error level with trace correlation fields
+// from ctx. If Config.SpanEvents is set the entry is also recorded as an
+// event on the active span.
+func ErrorContext(ctx context.Context, msg string, fields ...zap.Field) {
+	logContext(ctx, zapcore.ErrorLevel, msg, fields, true)
+}
+
+// CriticalContext logs a message at critical level with trace correlation
+// fields from ctx. Like ErrorContext, it records a span event if
+// Config.SpanEvents is set.
+func CriticalContext(ctx context.Context, msg string, fields ...zap.Field) {
+	logContext(ctx, CriticalLevel, msg, fields, true)
+}
+
+// contextLogger returns the logger stored in ctx or the global logger
+func contextLogger(ctx context.Context) *zap.Logger {
+	if v, ok := ctx.Value(contextKey{}).(*contextLoggers); ok && v.logger != nil {
+		return v.logger
+	}
+	return GetLogger()
+}
+
+// logContext is called directly by the Context functions, see check. The
+// middleware and Transport log without the caller, which is in net/http.
+func logContext(ctx context.Context, lvl zapcore.Level, msg string, fields []zap.Field, caller bool) {
+	g := acquire()
+	if g == nil {
+		return
+	}
+	defer g.done()
+	l := g.funcs
+	if v, ok := ctx.Value(contextKey{}).(*contextLoggers); ok && v.funcs != nil {
+		l = v.funcs
+	}
+	ce := check(l, g.caller && caller, lvl, msg)
+	if ce == nil {
+		return
+	}
+
+	if traceFields := TraceFields(ctx); len(traceFields) > 0 {
+		fields = append(traceFields, fields...)
+	}
+	ce.Write(fields...)
+
+	if g.spanEvents && levelAtLeast(lvl, zapcore.ErrorLevel) {
+		addSpanEvent(trace.SpanFromContext(ctx), lvl, msg, fields)
+	}
+}
+
+// addSpanEvent records an entry as a "log" event on span
+func addSpanEvent(span trace.Span, lvl zapcore.Level, msg string, fields []zap.Field) {
+	if !span.IsRecording() {
+		return
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	resolveLazy(enc.Fields)
+
+	attrs := make([]attribute.KeyValue, 0, len(enc.Fields)+2)
+	attrs = append(attrs,
+		attribute.String("log.severity", LevelName(lvl)),
+		attribute.String("log.message", msg),
+	)
+	for key, value := range enc.Fields {
+		switch key {
+		case TraceIDKey, SpanIDKey, TraceFlagsKey:
+			continue
+		}
+		attrs = append(attrs, toAttribute(key, value))
+	}
+	span.AddEvent("log", trace.WithAttributes(attrs...))
+}
+
+// toAttribute converts a value produced by zapcore.MapObjectEncoder to a
+// span attribute
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case int32:
+		return attribute.Int64(key, int64(v))
+	case uint32:
+		return attribute.Int64(key, int64(v))
+	case float64:
+		return attribute.Float64(key, v)
+	case float32:
+		return attribute.Float64(key, float64(v))
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
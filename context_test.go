@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+// newTestTracer returns a tracer whose ended spans are kept in memory
+func newTestTracer(t *testing.T) (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return recorder, provider
+}
+
+func TestContextTraceFields(t *testing.T) {
+	_, provider := newTestTracer(t)
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "operation")
+	defer span.End()
+	sc := span.SpanContext()
+
+	InfoContext(ctx, "with span", zap.String("key", "value"))
+	InfoContext(context.Background(), "without span")
+	FromContext(ctx).Info("from context")
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for _, i := range []int{0, 2} {
+		if entries[i][TraceIDKey] != sc.TraceID().String() {
+			t.Errorf("entry %d trace_id = %v, want %v", i, entries[i][TraceIDKey], sc.TraceID())
+		}
+		if entries[i][SpanIDKey] != sc.SpanID().String() {
+			t.Errorf("entry %d span_id = %v, want %v", i, entries[i][SpanIDKey], sc.SpanID())
+		}
+		if entries[i][TraceFlagsKey] != "01" {
+			t.Errorf("entry %d trace_flags = %v, want 01", i, entries[i][TraceFlagsKey])
+		}
+	}
+	if _, ok := entries[1][TraceIDKey]; ok {
+		t.Error("entry without a span has a trace_id")
+	}
+}
+
+func TestContextStoredLogger(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	ctx := NewContext(context.Background(), With(zap.String("request_id", "abc")))
+	WarnContext(ctx, "stored logger")
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 || entries[0]["request_id"] != "abc" {
+		t.Errorf("entries = %v, want one entry with request_id", entries)
+	}
+}
+
+func TestSpanEvents(t *testing.T) {
+	recorder, provider := newTestTracer(t)
+	config := DefaultConfig(Staging)
+	config.SpanEvents = true
+	initializeToFile(t, config)
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "operation")
+	InfoContext(ctx, "not recorded")
+	ErrorContext(ctx, "query failed", zap.String("table", "users"), zap.Int("attempt", 3))
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	events := spans[0].Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d span events, want 1", len(events))
+	}
+
+	attrs := map[string]string{}
+	for _, attr := range events[0].Attributes {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	want := map[string]string{
+		"log.severity": "error",
+		"log.message":  "query failed",
+		"table":        "users",
+		"attempt":      "3",
+	}
+	for key, value := range want {
+		if attrs[key] != value {
+			t.Errorf("event attribute %s = %q, want %q", key, attrs[key], value)
+		}
+	}
+	if _, ok := attrs[TraceIDKey]; ok {
+		t.Error("span event repeats the trace_id")
+	}
+}
+
+func TestSpanEventsCritical(t *testing.T) {
+	recorder, provider := newTestTracer(t)
+	config := DefaultConfig(Staging)
+	config.SpanEvents = true
+	read := initializeToFile(t, config)
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "operation")
+	NoticeContext(ctx, "not recorded")
+	CriticalContext(ctx, "database unreachable")
+	span.End()
+
+	events := recorder.Ended()[0].Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d span events, want 1", len(events))
+	}
+	for _, attr := range events[0].Attributes {
+		if attr.Key == "log.severity" && attr.Value.Emit() != "critical" {
+			t.Errorf("log.severity = %q, want critical", attr.Value.Emit())
+		}
+	}
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 2 || entries[0]["level"] != "notice" || entries[1]["level"] != "critical" || entries[1][TraceIDKey] == nil {
+		t.Errorf("entries = %v, want a notice and a critical entry with trace fields", entries)
+	}
+}
+
+func TestSpanEventsDisabled(t *testing.T) {
+	recorder, provider := newTestTracer(t)
+	initializeToFile(t, DefaultConfig(Staging))
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "operation")
+	ErrorContext(ctx, "query failed")
+	span.End()
+
+	if events := recorder.Ended()[0].Events(); len(events) != 0 {
+		t.Errorf("got %d span events with SpanEvents disabled, want 0", len(events))
+	}
+}
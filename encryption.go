@@ -0,0 +1,459 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// EncryptedScheme prefixes output paths that are written encrypted, e.g.
+// "encrypted:///var/log/app.log.enc". The rest of the path is a file path.
+const EncryptedScheme = "encrypted://"
+
+// ErrTruncatedLog is returned by DecryptLogFile if the log ends with an
+// incomplete record, e.g. after a crash. The complete records before it
+// have been written.
+var ErrTruncatedLog = errors.New("encrypted log ends with an incomplete record")
+
+// EncryptionKey is a key encrypting the data keys of encrypted outputs.
+// Each file segment is encrypted with its own random data key, which is
+// stored in the file wrapped by an EncryptionKey and tagged with its ID. A
+// segment starts when the file is opened and after segmentMaxRecords
+// records or segmentMaxBytes bytes.
+type EncryptionKey struct {
+	// ID identifies the key in the files it wrapped, at most 255 bytes
+	ID string
+	// Key is a 16, 24 or 32 byte AES key
+	Key []byte
+}
+
+// Encrypted log files start with encryptedMagic followed by frames of a
+// type byte, a big endian uint32 payload length and the payload. A header
+// frame starts a segment with a new data key; each record frame holds one
+// log entry sealed with the data key of its segment, using the index of the
+// record in the segment as the nonce. An existing file is appended to by
+// starting a new segment.
+const (
+	encryptedMagic = "LOGENC2\n"
+
+	frameHeader = 'H'
+	frameRecord = 'R'
+
+	frameHeaderSize = 5
+	dataKeySize     = 32
+	// maxFrameSize bounds the payload read back from a file
+	maxFrameSize = 64 << 20
+)
+
+// segmentMaxRecords and segmentMaxBytes bound the records and plaintext
+// bytes encrypted with one data key. They are variables for tests.
+var (
+	segmentMaxRecords uint64 = 1 << 20
+	segmentMaxBytes   int64  = 1 << 30
+)
+
+// validateEncryptionKeys checks that keys can be used to encrypt outputs
+func validateEncryptionKeys(keys []EncryptionKey) error {
+	if len(keys) == 0 {
+		return errors.New("no encryption keys configured")
+	}
+	for _, k := range keys {
+		if k.ID == "" || len(k.ID) > 255 {
+			return fmt.Errorf("encryption key ID %q must be 1 to 255 bytes", k.ID)
+		}
+		if _, err := aes.NewCipher(k.Key); err != nil {
+			return fmt.Errorf("encryption key %q: %w", k.ID, err)
+		}
+	}
+	return nil
+}
+
+// newGCM returns an AES-GCM AEAD for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce, which is prepended
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// recordNonce returns the nonce of the record at index in its segment. As
+// each segment has its own data key, a nonce is never used twice with a
+// key, and a record only decrypts at its own position.
+func recordNonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+// open decrypts the output of seal
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// appendFrame appends a frame of typ holding payload to b
+func appendFrame(b []byte, typ byte, payload []byte) []byte {
+	b = append(b, typ)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// wrapDataKey returns a header frame payload holding dataKey wrapped by kek.
+// The key ID is authenticated so that it cannot be swapped.
+func wrapDataKey(kek EncryptionKey, dataKey []byte) ([]byte, error) {
+	aead, err := newGCM(kek.Key)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(aead, dataKey, []byte(kek.ID))
+	if err != nil {
+		return nil, err
+	}
+	payload := append([]byte{byte(len(kek.ID))}, kek.ID...)
+	return append(payload, wrapped...), nil
+}
+
+// unwrapDataKey returns the data key of a header frame payload, unwrapped
+// with the key of keys it names
+func unwrapDataKey(payload []byte, keys []EncryptionKey) ([]byte, error) {
+	if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+		return nil, errors.New("malformed segment header")
+	}
+	id := string(payload[1 : 1+payload[0]])
+	for _, k := range keys {
+		if k.ID != id {
+			continue
+		}
+		aead, err := newGCM(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err := open(aead, payload[1+len(id):], []byte(id))
+		if err != nil {
+			return nil, fmt.Errorf("unwrapping data key with key %q: %w", id, err)
+		}
+		return dataKey, nil
+	}
+	return nil, fmt.Errorf("no encryption key with ID %q", id)
+}
+
+// encryptedSink writes each Write as one encrypted record frame
+type encryptedSink struct {
+	mu   sync.Mutex
+	file *os.File
+	kek  EncryptionKey
+	aead cipher.AEAD
+	// records and bytes count what the current segment holds
+	records uint64
+	bytes   int64
+}
+
+// openEncryptedSink opens path for appending and starts a new segment
+// with a random data key wrapped by kek
+func openEncryptedSink(path string, kek EncryptionKey) (*encryptedSink, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := startSegment(file, kek)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sink, nil
+}
+
+// startSegment writes the magic to an empty file, or checks it in an
+// existing one, and appends a header frame with a new data key
+func startSegment(file *os.File, kek EncryptionKey) (*encryptedSink, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	if info.Size() == 0 {
+		out = append(out, encryptedMagic...)
+	} else {
+		if err := readEncryptedMagic(io.NewSectionReader(file, 0, info.Size())); err != nil {
+			return nil, fmt.Errorf("existing file: %w", err)
+		}
+		// A record cut short by a crash would swallow the frames appended
+		// after it, so it is removed
+		end, err := completeFramesEnd(file, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		if end < info.Size() {
+			if err := file.Truncate(end); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	header, aead, err := newSegment(kek)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(appendFrame(out, frameHeader, header)); err != nil {
+		return nil, err
+	}
+	return &encryptedSink{file: file, kek: kek, aead: aead}, nil
+}
+
+// newSegment returns the header frame payload and the AEAD of a segment
+// with a new random data key wrapped by kek
+func newSegment(kek EncryptionKey) ([]byte, cipher.AEAD, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+	header, err := wrapDataKey(kek, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, aead, nil
+}
+
+// completeFramesEnd returns the offset after the last complete frame of an
+// encrypted log of size bytes. Only frame headers are read.
+func completeFramesEnd(file *os.File, size int64) (int64, error) {
+	offset := int64(len(encryptedMagic))
+	var header [frameHeaderSize]byte
+	for offset+frameHeaderSize <= size {
+		if _, err := file.ReadAt(header[:], offset); err != nil {
+			return 0, err
+		}
+		next := offset + frameHeaderSize + int64(binary.BigEndian.Uint32(header[1:]))
+		if next > size {
+			break
+		}
+		offset = next
+	}
+	return offset, nil
+}
+
+// Write implements zapcore.WriteSyncer. The frames are written with a
+// single write so that a crash can only truncate the last record.
+func (s *encryptedSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []byte
+	aead, records, bytes := s.aead, s.records, s.bytes
+	if records >= segmentMaxRecords || bytes >= segmentMaxBytes {
+		header, newAEAD, err := newSegment(s.kek)
+		if err != nil {
+			return 0, err
+		}
+		out = appendFrame(out, frameHeader, header)
+		aead, records, bytes = newAEAD, 0, 0
+	}
+	sealed := aead.Seal(nil, recordNonce(aead, records), p, nil)
+	if _, err := s.file.Write(appendFrame(out, frameRecord, sealed)); err != nil {
+		return 0, err
+	}
+	s.aead, s.records, s.bytes = aead, records+1, bytes+int64(len(p))
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer
+func (s *encryptedSink) Sync() error {
+	return s.file.Sync()
+}
+
+// Close closes the file
+func (s *encryptedSink) Close() error {
+	return s.file.Close()
+}
+
+// encryptedPath returns the file path of an encrypted output path
+func encryptedPath(path string) (string, bool) {
+	return strings.CutPrefix(path, EncryptedScheme)
+}
+
+// DecryptLogFile writes the entries of an encrypted log read from r to w.
+// keys must include the keys that wrapped the data keys of the log's
+// segments, e.g. Config.EncryptionKeys including retired keys. If the log
+// ends with an incomplete record, the complete records are written and
+// ErrTruncatedLog is returned.
+//
+// A record only decrypts at its position in its segment, so records that
+// were dropped, reordered or replayed within a segment are reported as an
+// error. Records removed from the end of a segment are not detected.
+func DecryptLogFile(r io.Reader, w io.Writer, keys ...EncryptionKey) error {
+	br := bufio.NewReader(r)
+	if err := readEncryptedMagic(br); err != nil {
+		return err
+	}
+
+	var aead cipher.AEAD
+	var index uint64
+	for frame := 1; ; frame++ {
+		typ, payload, err := readFrame(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case frameHeader:
+			dataKey, err := unwrapDataKey(payload, keys)
+			if err != nil {
+				return fmt.Errorf("frame %d: %w", frame, err)
+			}
+			if aead, err = newGCM(dataKey); err != nil {
+				return fmt.Errorf("frame %d: %w", frame, err)
+			}
+			index = 0
+		case frameRecord:
+			if aead == nil {
+				return fmt.Errorf("frame %d: record before segment header", frame)
+			}
+			plaintext, err := aead.Open(nil, recordNonce(aead, index), payload, nil)
+			if err != nil {
+				return fmt.Errorf("frame %d: decrypting record %d of its segment: %w", frame, index, err)
+			}
+			index++
+			if _, err := w.Write(plaintext); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("frame %d: unknown frame type %q", frame, typ)
+		}
+	}
+}
+
+// RewrapLogFile rotates the encryption key of an encrypted log file: the
+// data key of each segment is unwrapped with one of keys and wrapped again
+// with keys[0], after which the other keys are no longer needed to decrypt
+// it. Records are not re-encrypted. The file is replaced atomically and
+// must not be written to while it is rewrapped.
+func RewrapLogFile(path string, keys ...EncryptionKey) error {
+	if err := validateEncryptionKeys(keys); err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".rewrap-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := rewrap(bufio.NewReader(src), tmp, keys); err != nil {
+		return fmt.Errorf("rewrapping %s: %w", path, err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// rewrap copies an encrypted log from r to w, rewrapping each data key
+// with keys[0]
+func rewrap(r *bufio.Reader, w io.Writer, keys []EncryptionKey) error {
+	if err := readEncryptedMagic(r); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(encryptedMagic)
+	for frame := 1; ; frame++ {
+		typ, payload, err := readFrame(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if typ == frameHeader {
+			dataKey, err := unwrapDataKey(payload, keys)
+			if err != nil {
+				return fmt.Errorf("frame %d: %w", frame, err)
+			}
+			if payload, err = wrapDataKey(keys[0], dataKey); err != nil {
+				return err
+			}
+		}
+		if _, err := bw.Write(appendFrame(nil, typ, payload)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readEncryptedMagic checks that r starts with encryptedMagic
+func readEncryptedMagic(r io.Reader) error {
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, []byte(encryptedMagic)) {
+		return errors.New("not an encrypted log")
+	}
+	return nil
+}
+
+// readFrame reads the next frame. It returns io.EOF at the end of r and
+// ErrTruncatedLog if r ends within a frame.
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, ErrTruncatedLog
+		}
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the maximum size", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, ErrTruncatedLog
+		}
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
@@ -0,0 +1,235 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+var (
+	oldKey = EncryptionKey{ID: "2024-01", Key: bytes.Repeat([]byte{1}, 32)}
+	newKey = EncryptionKey{ID: "2024-02", Key: bytes.Repeat([]byte{2}, 16)}
+)
+
+// initializeEncrypted initializes the logger with an encrypted output
+func initializeEncrypted(t *testing.T, path string, keys ...EncryptionKey) {
+	t.Helper()
+	config := DefaultConfig(Staging)
+	config.OutputPaths = []string{EncryptedScheme + path}
+	config.EncryptionKeys = keys
+	if err := Initialize(config); err != nil {
+		t.Fatalf("Initialize() error = %v, want nil", err)
+	}
+	t.Cleanup(func() { Initialize(DefaultConfig(Test)) })
+}
+
+// decryptLines decrypts the file at path
+func decryptLines(t *testing.T, path string, keys ...EncryptionKey) ([]string, error) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading encrypted log: %v", err)
+	}
+	var out bytes.Buffer
+	err = DecryptLogFile(bytes.NewReader(data), &out, keys...)
+	return strings.Split(strings.TrimSpace(out.String()), "\n"), err
+}
+
+func TestEncryptedOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.enc")
+	initializeEncrypted(t, path, oldKey)
+
+	Info("card charged", zap.String("card", "4111 1111 1111 1111"))
+	Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("4111")) || bytes.Contains(data, []byte("card charged")) {
+		t.Error("encrypted output contains plaintext")
+	}
+
+	lines, err := decryptLines(t, path, oldKey)
+	if err != nil {
+		t.Fatalf("DecryptLogFile() error = %v", err)
+	}
+	entries := decodeEntries(t, lines)
+	if len(entries) != 1 || entries[0]["msg"] != "card charged" {
+		t.Errorf("decrypted entries = %v", entries)
+	}
+
+	if _, err := decryptLines(t, path, newKey); err == nil {
+		t.Error("DecryptLogFile() with an unknown key succeeded")
+	}
+	wrongKey := EncryptionKey{ID: oldKey.ID, Key: newKey.Key}
+	if _, err := decryptLines(t, path, wrongKey); err == nil {
+		t.Error("DecryptLogFile() with a wrong key succeeded")
+	}
+}
+
+func TestEncryptedOutputKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.enc")
+	initializeEncrypted(t, path, oldKey)
+	Info("before rotation")
+
+	// Reinitializing with a new first key starts a segment under it
+	initializeEncrypted(t, path, newKey, oldKey)
+	Info("after rotation")
+	Sync()
+
+	lines, err := decryptLines(t, path, newKey, oldKey)
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("DecryptLogFile() = %v, %v", lines, err)
+	}
+	if _, err := decryptLines(t, path, newKey); err == nil {
+		t.Error("DecryptLogFile() without the retired key succeeded")
+	}
+
+	Initialize(DefaultConfig(Test))
+	if err := RewrapLogFile(path, newKey, oldKey); err != nil {
+		t.Fatalf("RewrapLogFile() error = %v", err)
+	}
+	lines, err = decryptLines(t, path, newKey)
+	if err != nil || len(lines) != 2 || !strings.Contains(lines[0], "before rotation") {
+		t.Errorf("after rewrap DecryptLogFile() = %v, %v", lines, err)
+	}
+}
+
+func TestEncryptedOutputTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.enc")
+	initializeEncrypted(t, path, oldKey)
+	Info("first")
+	Info("second")
+	Sync()
+	Initialize(DefaultConfig(Test))
+
+	// Simulate a crash in the middle of writing the last record
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := decryptLines(t, path, oldKey)
+	if !errors.Is(err, ErrTruncatedLog) {
+		t.Errorf("DecryptLogFile() error = %v, want ErrTruncatedLog", err)
+	}
+	if len(lines) != 1 || !strings.Contains(lines[0], "first") {
+		t.Errorf("decrypted lines = %v", lines)
+	}
+
+	// Appending drops the incomplete record so that later records decrypt
+	initializeEncrypted(t, path, oldKey)
+	Info("third")
+	Sync()
+	lines, err = decryptLines(t, path, oldKey)
+	if err != nil || len(lines) != 2 || !strings.Contains(lines[1], "third") {
+		t.Errorf("DecryptLogFile() = %v, %v", lines, err)
+	}
+}
+
+// encryptedFrames splits the encrypted log at path into its frames
+func encryptedFrames(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data[len(encryptedMagic):])
+	var frames [][]byte
+	for {
+		typ, payload, err := readFrame(r)
+		if err == io.EOF {
+			return frames
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, appendFrame(nil, typ, payload))
+	}
+}
+
+func TestEncryptedOutputSegmentBudget(t *testing.T) {
+	defer func(records uint64) { segmentMaxRecords = records }(segmentMaxRecords)
+	segmentMaxRecords = 2
+
+	path := filepath.Join(t.TempDir(), "app.log.enc")
+	initializeEncrypted(t, path, oldKey)
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		Info(msg)
+	}
+	Sync()
+
+	headers := 0
+	for _, frame := range encryptedFrames(t, path) {
+		if frame[0] == frameHeader {
+			headers++
+		}
+	}
+	if headers != 3 {
+		t.Errorf("got %d segments for 5 records, want 3", headers)
+	}
+	lines, err := decryptLines(t, path, oldKey)
+	if err != nil || len(lines) != 5 || !strings.Contains(lines[4], "five") {
+		t.Errorf("DecryptLogFile() = %v, %v", lines, err)
+	}
+}
+
+func TestEncryptedOutputRecordOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.enc")
+	initializeEncrypted(t, path, oldKey)
+	Info("first")
+	Info("second")
+	Info("third")
+	Sync()
+	frames := encryptedFrames(t, path)
+	header, first, second, third := frames[0], frames[1], frames[2], frames[3]
+
+	tests := map[string][][]byte{
+		"dropped":   {header, first, third},
+		"reordered": {header, second, first, third},
+		"replayed":  {header, first, first, second},
+	}
+	for name, frames := range tests {
+		tampered := append([]byte(encryptedMagic), bytes.Join(frames, nil)...)
+		if err := DecryptLogFile(bytes.NewReader(tampered), io.Discard, oldKey); err == nil {
+			t.Errorf("%s: DecryptLogFile() error = nil, want an error", name)
+		}
+	}
+}
+
+func TestEncryptedOutputErrors(t *testing.T) {
+	dir := t.TempDir()
+	defer Initialize(DefaultConfig(Test))
+
+	plain := filepath.Join(dir, "plain.log")
+	if err := os.WriteFile(plain, []byte("not encrypted\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+		keys []EncryptionKey
+	}{
+		{"no keys", filepath.Join(dir, "a.enc"), nil},
+		{"bad key size", filepath.Join(dir, "b.enc"), []EncryptionKey{{ID: "k", Key: []byte("short")}}},
+		{"missing ID", filepath.Join(dir, "c.enc"), []EncryptionKey{{Key: oldKey.Key}}},
+		{"plain file", plain, []EncryptionKey{oldKey}},
+	}
+	for _, tt := range tests {
+		config := DefaultConfig(Staging)
+		config.OutputPaths = []string{EncryptedScheme + tt.path}
+		config.EncryptionKeys = tt.keys
+		if err := Initialize(config); err == nil {
+			t.Errorf("%s: Initialize() error = nil, want error", tt.name)
+		}
+	}
+}
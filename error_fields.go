@@ -0,0 +1,204 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxErrorNodes bounds the number of errors rendered by Err, guarding
+// against very deep or cyclic chains
+const maxErrorNodes = 64
+
+// LogFielder is implemented by errors that carry their own structured
+// context. Err adds the fields of every error in the chain to the entry.
+type LogFielder interface {
+	LogFields() []zap.Field
+}
+
+// Err is like zap.Error, but also records the chain of wrapped errors:
+//
+//   - error: the error message
+//   - error_chain: the errors.Unwrap chain as an array of {type, message};
+//     errors joined with errors.Join, or wrapping several errors with
+//     Unwrap() []error, have a causes array holding one chain per error
+//   - error_stack: the stack trace of the innermost error that carries one,
+//     through a pkg/errors style StackTrace() method
+//
+// Fields from errors in the chain implementing LogFielder are added as
+// well. A nil error adds nothing.
+func Err(err error) zap.Field {
+	return NamedErr("error", err)
+}
+
+// NamedErr is like Err with a different key, which also prefixes the
+// _chain and _stack keys
+func NamedErr(key string, err error) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	return zap.Inline(errorFields{key: key, err: err})
+}
+
+// errorFields renders an error as several fields of the enclosing entry
+type errorFields struct {
+	key string
+	err error
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler. Like zap.Error, it
+// survives Error methods that panic, e.g. on nil pointer receivers.
+func (e errorFields) MarshalLogObject(enc zapcore.ObjectEncoder) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("PANIC=%v", r)
+		}
+	}()
+
+	enc.AddString(e.key, e.err.Error())
+
+	budget := maxErrorNodes
+	if err := enc.AddArray(e.key+"_chain", errorChain{err: e.err, budget: &budget}); err != nil {
+		return err
+	}
+
+	var stack string
+	walkErrors(e.err, func(err error) {
+		if s := errorStack(err); s != "" {
+			stack = s
+		}
+		if f, ok := err.(LogFielder); ok {
+			for _, field := range f.LogFields() {
+				field.AddTo(enc)
+			}
+		}
+	})
+	if stack != "" {
+		enc.AddString(e.key+"_stack", stack)
+	}
+	return nil
+}
+
+// errorChain renders err and the errors it wraps, stopping at an error
+// that wraps several
+type errorChain struct {
+	err    error
+	budget *int
+}
+
+// MarshalLogArray implements zapcore.ArrayMarshaler
+func (c errorChain) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for err := c.err; err != nil && *c.budget > 0; {
+		*c.budget--
+		node := errorNode{err: err, budget: c.budget}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			node.causes = u.Unwrap()
+			err = nil
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			err = nil
+		}
+		if e := enc.AppendObject(node); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// errorNode is one error of a chain
+type errorNode struct {
+	err    error
+	causes []error
+	budget *int
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (n errorNode) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("type", reflect.TypeOf(n.err).String())
+	enc.AddString("message", n.err.Error())
+	if len(n.causes) == 0 {
+		return nil
+	}
+	return enc.AddArray("causes", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, cause := range n.causes {
+			if cause == nil {
+				continue
+			}
+			if err := arr.AppendArray(errorChain{err: cause, budget: n.budget}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// walkErrors calls fn for err and every error it wraps, depth first
+func walkErrors(err error, fn func(error)) {
+	budget := maxErrorNodes
+	var walk func(error)
+	walk = func(err error) {
+		for err != nil && budget > 0 {
+			budget--
+			fn(err)
+			switch u := err.(type) {
+			case interface{ Unwrap() []error }:
+				for _, cause := range u.Unwrap() {
+					walk(cause)
+				}
+				return
+			case interface{ Unwrap() error }:
+				err = u.Unwrap()
+			default:
+				return
+			}
+		}
+	}
+	walk(err)
+}
+
+// errorStack returns the stack trace carried by err, in the format zap uses
+// for entry stack traces. Errors created by github.com/pkg/errors and
+// similar packages have a StackTrace method returning program counters; it
+// is found by reflection so that those packages are not dependencies.
+func errorStack(err error) string {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+	trace := method.Call(nil)[0]
+	if trace.Kind() != reflect.Slice || trace.Type().Elem().Kind() != reflect.Uintptr || trace.Len() == 0 {
+		return ""
+	}
+
+	pcs := make([]uintptr, trace.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(trace.Index(i).Uint())
+	}
+	frames := runtime.CallersFrames(pcs)
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(frame.Function)
+			b.WriteString("\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
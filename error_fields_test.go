@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// testFrame and testStackTrace mimic the types of github.com/pkg/errors
+type testFrame uintptr
+
+type testStackTrace []testFrame
+
+// stackError carries the stack where it was created, like pkg/errors
+type stackError struct {
+	msg   string
+	stack []uintptr
+}
+
+func newStackError(msg string) *stackError {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(1, pcs)
+	return &stackError{msg: msg, stack: pcs[:n]}
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() testStackTrace {
+	trace := make(testStackTrace, len(e.stack))
+	for i, pc := range e.stack {
+		trace[i] = testFrame(pc)
+	}
+	return trace
+}
+
+// fieldsError carries structured context
+type fieldsError struct {
+	err error
+}
+
+func (e fieldsError) Error() string { return "order rejected: " + e.err.Error() }
+
+func (e fieldsError) Unwrap() error { return e.err }
+
+func (e fieldsError) LogFields() []zap.Field {
+	return []zap.Field{zap.String("order_id", "A1"), zap.Int("attempt", 3)}
+}
+
+func TestErrChain(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	err := fmt.Errorf("checkout: %w", fieldsError{newStackError("card declined")})
+	Warn("failed", Err(err))
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry["error"] != "checkout: order rejected: card declined" {
+		t.Errorf("error = %v", entry["error"])
+	}
+	if entry["order_id"] != "A1" || entry["attempt"] != float64(3) {
+		t.Errorf("custom fields = %v %v", entry["order_id"], entry["attempt"])
+	}
+
+	chain, _ := entry["error_chain"].([]interface{})
+	wantTypes := []string{"*fmt.wrapError", "logger.fieldsError", "*logger.stackError"}
+	if len(chain) != len(wantTypes) {
+		t.Fatalf("error_chain = %v", entry["error_chain"])
+	}
+	for i, want := range wantTypes {
+		node := chain[i].(map[string]interface{})
+		if node["type"] != want {
+			t.Errorf("error_chain[%d].type = %v, want %v", i, node["type"], want)
+		}
+	}
+	if node := chain[2].(map[string]interface{}); node["message"] != "card declined" {
+		t.Errorf("error_chain[2].message = %v", node["message"])
+	}
+
+	stack, _ := entry["error_stack"].(string)
+	if !strings.HasPrefix(stack, "github.com/kingrain94/logger.newStackError\n\t") {
+		t.Errorf("error_stack = %q", stack)
+	}
+}
+
+func TestErrJoin(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	err := fmt.Errorf("cleanup: %w", errors.Join(fs.ErrNotExist, fmt.Errorf("close: %w", fs.ErrClosed)))
+	Warn("failed", NamedErr("cause", err), Err(nil))
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if _, ok := entry["error"]; ok {
+		t.Error("Err(nil) added an error field")
+	}
+
+	chain, _ := entry["cause_chain"].([]interface{})
+	if len(chain) != 2 {
+		t.Fatalf("cause_chain = %v", entry["cause_chain"])
+	}
+	join := chain[1].(map[string]interface{})
+	causes, _ := join["causes"].([]interface{})
+	if join["type"] != "*errors.joinError" || len(causes) != 2 {
+		t.Fatalf("join node = %v", join)
+	}
+	second, _ := causes[1].([]interface{})
+	if len(second) != 2 || second[1].(map[string]interface{})["message"] != "file already closed" {
+		t.Errorf("second cause = %v", causes[1])
+	}
+	if _, ok := entry["cause_stack"]; ok {
+		t.Error("cause_stack added for errors without a stack trace")
+	}
+}
+
+// cyclicError unwraps to itself
+type cyclicError struct{}
+
+func (e *cyclicError) Error() string { return "cycle" }
+
+func (e *cyclicError) Unwrap() error { return e }
+
+func TestErrBounded(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	Warn("cycle", Err(&cyclicError{}))
+
+	entries := decodeEntries(t, read())
+	if chain, _ := entries[0]["error_chain"].([]interface{}); len(chain) != maxErrorNodes {
+		t.Errorf("got %d chain entries, want %d", len(chain), maxErrorNodes)
+	}
+}
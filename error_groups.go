@@ -0,0 +1,303 @@
+package logger
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultMaxErrorGroups bounds the number of fingerprints kept in memory.
+// When it is reached the group seen least recently is evicted.
+const DefaultMaxErrorGroups = 1000
+
+// fingerprintFrames is the number of top stack frames in a fingerprint
+const fingerprintFrames = 3
+
+// packageFuncPrefix prefixes the functions of this package, which are
+// skipped in fingerprints since every entry logged through the package
+// functions passes through them
+const packageFuncPrefix = "github.com/kingrain94/logger."
+
+// ErrorGroup aggregates entries at Error and above sharing a fingerprint
+type ErrorGroup struct {
+	// Fingerprint identifies the group. It is derived from Message,
+	// ErrorType and Frames.
+	Fingerprint string `json:"fingerprint"`
+	// Message is the entry message with numbers, IDs and quoted strings
+	// replaced by placeholders
+	Message string `json:"message"`
+	// ErrorType is the type of the root cause of the entry's error field
+	ErrorType string `json:"error_type,omitempty"`
+	// Frames are the top functions of the entry's stack trace
+	Frames []string `json:"frames,omitempty"`
+
+	Count     uint64      `json:"count"`
+	FirstSeen time.Time   `json:"first_seen"`
+	LastSeen  time.Time   `json:"last_seen"`
+	Sample    ErrorSample `json:"sample"`
+}
+
+// ErrorSample is the first entry seen for an ErrorGroup
+type ErrorSample struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Logger  string                 `json:"logger,omitempty"`
+	Caller  string                 `json:"caller,omitempty"`
+	Message string                 `json:"msg"`
+	Error   string                 `json:"error,omitempty"`
+	Stack   string                 `json:"stacktrace,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// errorGroups aggregates the entries at Error and above logged by loggers
+// built by this package while Config.ErrorSummary or Config.Sentry is set
+var errorGroups = &errorAggregator{groups: map[string]*ErrorGroup{}, max: DefaultMaxErrorGroups}
+
+// removeErrorGroups removes the hook adding entries to errorGroups, or is
+// nil if it is not added. It is guarded by mu.
+var removeErrorGroups func()
+
+// enableErrorGroups adds or removes the hook adding entries to errorGroups.
+// It is called by Initialize with mu held.
+func enableErrorGroups(enabled bool) {
+	switch {
+	case enabled && removeErrorGroups == nil:
+		removeErrorGroups = AddHook(zapcore.ErrorLevel, errorGroups.add)
+	case !enabled && removeErrorGroups != nil:
+		removeErrorGroups()
+		removeErrorGroups = nil
+	}
+}
+
+// errorAggregator groups entries by fingerprint
+type errorAggregator struct {
+	mu     sync.Mutex
+	groups map[string]*ErrorGroup
+	max    int
+}
+
+// add is the hook recording an entry in its group. A new group is forwarded
+// to Sentry if Config.Sentry is set.
+func (a *errorAggregator) add(ent zapcore.Entry, fields []zapcore.Field) error {
+	key := newErrorKey(ent, fields)
+	fingerprint := key.fingerprint()
+	if a.count(fingerprint, ent.Time) {
+		return nil
+	}
+
+	// The sample, which encodes every field, is only built for new groups
+	group := newErrorGroup(ent, fields, key, fingerprint)
+	a.mu.Lock()
+	if existing, ok := a.groups[fingerprint]; ok {
+		existing.Count++
+		existing.LastSeen = ent.Time
+		a.mu.Unlock()
+		return nil
+	}
+	if len(a.groups) >= a.max {
+		a.evictLocked()
+	}
+	a.groups[fingerprint] = group
+	a.mu.Unlock()
+
+	if f := sentry.Load(); f != nil {
+		sent := *group
+		f.enqueue(&sent)
+	}
+	return nil
+}
+
+// count counts an entry seen at t in the group with fingerprint and
+// reports whether the group exists
+func (a *errorAggregator) count(fingerprint string, t time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	existing, ok := a.groups[fingerprint]
+	if ok {
+		existing.Count++
+		existing.LastSeen = t
+	}
+	return ok
+}
+
+// evictLocked removes the group seen least recently
+func (a *errorAggregator) evictLocked() {
+	var oldest *ErrorGroup
+	for _, g := range a.groups {
+		if oldest == nil || g.LastSeen.Before(oldest.LastSeen) {
+			oldest = g
+		}
+	}
+	if oldest != nil {
+		delete(a.groups, oldest.Fingerprint)
+	}
+}
+
+// errorKey is what identifies the group of an entry
+type errorKey struct {
+	message   string
+	errorType string
+	frames    []string
+	// err is the entry's error field, or nil
+	err error
+}
+
+// newErrorKey returns the key of an entry without encoding its fields
+func newErrorKey(ent zapcore.Entry, fields []zapcore.Field) errorKey {
+	key := errorKey{
+		message: normalizeMessage(ent.Message),
+		frames:  stackFunctions(ent.Stack, fingerprintFrames),
+	}
+	if len(key.frames) == 0 && ent.Caller.Defined {
+		key.frames = []string{ent.Caller.Function}
+	}
+	for _, f := range fields {
+		switch v := f.Interface.(type) {
+		case error:
+			if f.Type == zapcore.ErrorType {
+				key.err = v
+			}
+		case errorFields:
+			key.err = v.err
+		}
+		if key.err != nil {
+			key.errorType = rootErrorType(key.err)
+			break
+		}
+	}
+	return key
+}
+
+// fingerprint returns the fingerprint of the group of k
+func (k errorKey) fingerprint() string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", k.message, k.errorType, strings.Join(k.frames, "\x00"))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// newErrorGroup returns a group holding a single entry with key
+func newErrorGroup(ent zapcore.Entry, fields []zapcore.Field, key errorKey, fingerprint string) *ErrorGroup {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	resolveLazy(enc.Fields)
+
+	group := &ErrorGroup{
+		Fingerprint: fingerprint,
+		Message:     key.message,
+		ErrorType:   key.errorType,
+		Frames:      key.frames,
+		Count:       1,
+		FirstSeen:   ent.Time,
+		LastSeen:    ent.Time,
+		Sample: ErrorSample{
+			Time:    ent.Time,
+			Level:   LevelName(ent.Level),
+			Logger:  ent.LoggerName,
+			Message: ent.Message,
+			Stack:   ent.Stack,
+			Fields:  enc.Fields,
+		},
+	}
+	if ent.Caller.Defined {
+		group.Sample.Caller = ent.Caller.TrimmedPath()
+	}
+	if key.err != nil {
+		group.Sample.Error = key.err.Error()
+	}
+	return group
+}
+
+// Patterns replaced by normalizeMessage, most specific first
+var messagePlaceholders = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{16,}\b`), "<hex>"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?\b`), "<num>"},
+}
+
+// normalizeMessage approximates the template of a formatted message by
+// replacing the values most likely to vary between occurrences
+func normalizeMessage(msg string) string {
+	for _, p := range messagePlaceholders {
+		msg = p.pattern.ReplaceAllString(msg, p.placeholder)
+	}
+	return msg
+}
+
+// rootErrorType returns the type of the innermost error wrapped by err
+func rootErrorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
+
+// stackFunctions returns up to n function names outside this package from
+// a stack trace in the format produced by zap, where each function is
+// followed by an indented file:line
+func stackFunctions(stack string, n int) []string {
+	var functions []string
+	for _, line := range strings.Split(stack, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, packageFuncPrefix) {
+			continue
+		}
+		functions = append(functions, line)
+		if len(functions) == n {
+			break
+		}
+	}
+	return functions
+}
+
+// ErrorSummary returns the groups of entries at Error and above logged
+// since the process started, most frequent first
+func ErrorSummary() []ErrorGroup {
+	errorGroups.mu.Lock()
+	groups := make([]ErrorGroup, 0, len(errorGroups.groups))
+	for _, g := range errorGroups.groups {
+		groups = append(groups, *g)
+	}
+	errorGroups.mu.Unlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].LastSeen.After(groups[j].LastSeen)
+	})
+	return groups
+}
+
+// ResetErrorSummary discards all error groups. Fingerprints seen afterwards
+// are forwarded to Sentry again.
+func ResetErrorSummary() {
+	errorGroups.mu.Lock()
+	defer errorGroups.mu.Unlock()
+	errorGroups.groups = map[string]*ErrorGroup{}
+}
+
+// ErrorSummaryHandler serves ErrorSummary as JSON
+func ErrorSummaryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ErrorSummary())
+	})
+}
@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// failLookup logs an error from a single call site so that every call has
+// the same stack
+func failLookup(id int, err error) {
+	Error(fmt.Sprintf("lookup of user %d failed", id), zap.Error(err))
+}
+
+func TestErrorSummary(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.ErrorSummary = true
+	initializeToFile(t, config)
+	ResetErrorSummary()
+	t.Cleanup(ResetErrorSummary)
+
+	failLookup(1, fmt.Errorf("query: %w", fs.ErrNotExist))
+	failLookup(2, fmt.Errorf("query: %w", fs.ErrNotExist))
+	failLookup(3, context.DeadlineExceeded)
+	Warn("not aggregated")
+
+	groups := ErrorSummary()
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(groups), groups)
+	}
+
+	first := groups[0]
+	if first.Count != 2 || first.Message != "lookup of user <num> failed" || first.ErrorType != "*errors.errorString" {
+		t.Errorf("first group = %+v", first)
+	}
+	if len(first.Frames) == 0 {
+		t.Error("first group has no frames")
+	}
+	if first.Sample.Message != "lookup of user 1 failed" || first.Sample.Error != "query: file does not exist" {
+		t.Errorf("sample = %+v", first.Sample)
+	}
+	if first.LastSeen.Before(first.FirstSeen) {
+		t.Errorf("last seen %v is before first seen %v", first.LastSeen, first.FirstSeen)
+	}
+	if groups[1].Count != 1 || groups[1].Fingerprint == first.Fingerprint {
+		t.Errorf("second group = %+v", groups[1])
+	}
+
+	rec := httptest.NewRecorder()
+	ErrorSummaryHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/errors", nil))
+	var served []ErrorGroup
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(served) != 2 || served[0].Fingerprint != first.Fingerprint {
+		t.Errorf("served groups = %+v", served)
+	}
+}
+
+func TestErrorSummaryDisabled(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Staging))
+	ResetErrorSummary()
+	t.Cleanup(ResetErrorSummary)
+
+	failLookup(1, fs.ErrNotExist)
+	if groups := ErrorSummary(); len(groups) != 0 {
+		t.Errorf("got %d groups without Config.ErrorSummary, want 0", len(groups))
+	}
+}
+
+func TestNormalizeMessage(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"order 42 failed after 1.5 seconds", "order <num> failed after <num> seconds"},
+		{`user "alice" not found`, "user <str> not found"},
+		{"request 123e4567-e89b-12d3-a456-426614174000 timed out", "request <uuid> timed out"},
+		{"bad pointer 0xc000012345", "bad pointer <hex>"},
+		{"no placeholders", "no placeholders"},
+	}
+	for _, tt := range tests {
+		if got := normalizeMessage(tt.msg); got != tt.want {
+			t.Errorf("normalizeMessage(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestErrorSummaryEviction(t *testing.T) {
+	a := &errorAggregator{groups: map[string]*ErrorGroup{}, max: 2}
+	start := time.Now()
+	for i, msg := range []string{"first", "second", "third"} {
+		a.add(zapcore.Entry{Level: zapcore.ErrorLevel, Time: start.Add(time.Duration(i) * time.Second), Message: msg}, nil)
+	}
+	if len(a.groups) != 2 {
+		t.Errorf("got %d groups, want 2", len(a.groups))
+	}
+	for _, g := range a.groups {
+		if g.Sample.Message == "first" {
+			t.Error("the least recently seen group was not evicted")
+		}
+	}
+}
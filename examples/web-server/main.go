@@ -13,61 +13,35 @@ func main() {
 	// Initialize logger for production
 	logger.SetEnvironment(logger.Production)
 
-	// Create a service logger with common fields
-	serviceLogger := logger.With(
-		zap.String("service", "web-api"),
-		zap.String("version", "1.0.0"),
-	)
-
-	// Middleware for request logging
-	loggingMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-
-			// Log incoming request
-			serviceLogger.Info("Request started",
-				zap.String("method", r.Method),
-				zap.String("path", r.URL.Path),
-				zap.String("remote_addr", r.RemoteAddr),
-				zap.String("user_agent", r.UserAgent()),
-			)
-
-			// Call the actual handler
-			next(w, r)
+	// Ensure logs are flushed on a normal return; Fatal runs the exit hooks
+	// and flushes the logger itself, since deferred calls do not run on exit
+	defer logger.Sync()
 
-			// Log request completion
-			duration := time.Since(start)
-			serviceLogger.Info("Request completed",
-				zap.String("method", r.Method),
-				zap.String("path", r.URL.Path),
-				zap.Duration("duration", duration),
-			)
-		}
-	}
+	mux := http.NewServeMux()
 
 	// Home handler
-	http.HandleFunc("/", loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		logger.Debug("Processing home request")
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		logger.DebugContext(r.Context(), "Processing home request")
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "Hello, World! Time: %s", time.Now().Format(time.RFC3339))
-	}))
+	})
 
 	// Health check handler
-	http.HandleFunc("/health", loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		logger.Debug("Health check requested")
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		logger.DebugContext(r.Context(), "Health check requested")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok","timestamp":"` + time.Now().Format(time.RFC3339) + `"}`))
-	}))
+	})
 
 	// Error simulation handler
-	http.HandleFunc("/error", loggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		logger.Error("Simulated error occurred",
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		logger.ErrorContext(r.Context(), "Simulated error occurred",
 			zap.String("path", r.URL.Path),
 			zap.String("error", "simulated database connection failed"),
 		)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Internal server error"}`))
-	}))
+	})
 
 	// Start server
 	port := 8080
@@ -76,13 +50,12 @@ func main() {
 		zap.String("environment", "production"),
 	)
 
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil); err != nil {
+	// The middleware logs every request with trace and request correlation
+	// IDs and makes a request-scoped logger available to the handlers
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), logger.Middleware(mux)); err != nil {
 		logger.Fatal("Failed to start server",
 			zap.Error(err),
 			zap.Int("port", port),
 		)
 	}
-
-	// Ensure logs are flushed on exit
-	defer logger.Sync()
 }
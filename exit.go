@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultExitTimeout bounds how long exit hooks may run before a Fatal entry
+// terminates the program
+const DefaultExitTimeout = 5 * time.Second
+
+// FatalHandler decides what happens once a Fatal entry has been written and
+// the exit hooks have run. If it returns, the Fatal call returns to its
+// caller, which is mostly useful in tests.
+type FatalHandler func(zapcore.Entry)
+
+// ExitOnFatal exits the program with status 1. This is the default.
+func ExitOnFatal(zapcore.Entry) {
+	os.Exit(1)
+}
+
+// PanicOnFatal panics with the entry message instead of exiting, allowing
+// deferred functions to run
+func PanicOnFatal(ent zapcore.Entry) {
+	panic(ent.Message)
+}
+
+// GoexitOnFatal ends the calling goroutine with runtime.Goexit, like
+// testing.T.FailNow does
+func GoexitOnFatal(zapcore.Entry) {
+	runtime.Goexit()
+}
+
+// exitHook is a registered exit hook. Hooks are compared by pointer so that
+// the same function can be registered more than once.
+type exitHook struct {
+	fn func()
+}
+
+var (
+	exitMu    sync.Mutex
+	exitHooks []*exitHook
+)
+
+// RegisterExitHook registers fn to run before a Fatal entry terminates the
+// program, for example to flush buffers or close files. Hooks run in LIFO
+// order, at most once, and are bounded by Config.ExitTimeout in total. The
+// returned function unregisters the hook.
+func RegisterExitHook(fn func()) func() {
+	hook := &exitHook{fn: fn}
+
+	exitMu.Lock()
+	exitHooks = append(exitHooks, hook)
+	exitMu.Unlock()
+
+	return func() {
+		exitMu.Lock()
+		defer exitMu.Unlock()
+		for i, h := range exitHooks {
+			if h == hook {
+				exitHooks = append(exitHooks[:i], exitHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// runExitHooks runs the registered hooks in LIFO order followed by a Sync of
+// the logger, giving up after timeout. Must not be called with mu held.
+func runExitHooks(timeout time.Duration) {
+	exitMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := len(hooks) - 1; i >= 0; i-- {
+			runExitHook(hooks[i])
+		}
+		_ = Sync()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+}
+
+// runExitHook runs a single hook, recovering from panics so that one broken
+// hook does not prevent the others from running
+func runExitHook(hook *exitHook) {
+	defer func() { _ = recover() }()
+	hook.fn()
+}
+
+// fatalHook is installed as zap's fatal hook. zap calls it after the Fatal
+// entry has been written.
+type fatalHook struct {
+	handler FatalHandler
+	timeout time.Duration
+}
+
+func newFatalHook(handler FatalHandler, timeout time.Duration) fatalHook {
+	if handler == nil {
+		handler = ExitOnFatal
+	}
+	if timeout <= 0 {
+		timeout = DefaultExitTimeout
+	}
+	return fatalHook{handler: handler, timeout: timeout}
+}
+
+// OnWrite implements zapcore.CheckWriteHook
+func (h fatalHook) OnWrite(ce *zapcore.CheckedEntry, _ []zapcore.Field) {
+	runExitHooks(h.timeout)
+	h.handler(ce.Entry)
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFatalHandler(t *testing.T) {
+	var (
+		handled   []string
+		lockFree  bool
+		hookOrder []int
+	)
+	config := DefaultConfig(Staging)
+	config.OnFatal = func(ent zapcore.Entry) {
+		handled = append(handled, ent.Message)
+		if mu.TryLock() {
+			lockFree = true
+			mu.Unlock()
+		}
+	}
+	read := initializeToFile(t, config)
+
+	for i := 1; i <= 3; i++ {
+		i := i
+		RegisterExitHook(func() { hookOrder = append(hookOrder, i) })
+	}
+	unregister := RegisterExitHook(func() { hookOrder = append(hookOrder, 99) })
+	unregister()
+
+	Fatal("fatal message")
+	Fatalf("fatal %s", "formatted")
+	Fatalw("fatal kv", "key", "value")
+
+	if got := strings.Join(handled, ","); got != "fatal message,fatal formatted,fatal kv" {
+		t.Errorf("OnFatal received %q", got)
+	}
+	if !lockFree {
+		t.Error("logger lock was held while OnFatal ran")
+	}
+	if len(hookOrder) != 3 || hookOrder[0] != 3 || hookOrder[1] != 2 || hookOrder[2] != 1 {
+		t.Errorf("exit hooks ran in order %v, want [3 2 1] once", hookOrder)
+	}
+	if entries := decodeEntries(t, read()); len(entries) != 3 {
+		t.Errorf("got %d entries, want 3", len(entries))
+	}
+}
+
+func TestExitHookTimeout(t *testing.T) {
+	config := DefaultConfig(Test)
+	config.ExitTimeout = 20 * time.Millisecond
+	config.OnFatal = func(zapcore.Entry) {}
+	initializeToFile(t, config)
+
+	release := make(chan struct{})
+	defer close(release)
+	RegisterExitHook(func() { <-release })
+
+	start := time.Now()
+	Fatal("fatal message")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fatal() took %v, want it bounded by ExitTimeout", elapsed)
+	}
+}
+
+func TestExitHookPanic(t *testing.T) {
+	config := DefaultConfig(Test)
+	config.OnFatal = func(zapcore.Entry) {}
+	initializeToFile(t, config)
+
+	ran := false
+	RegisterExitHook(func() { ran = true })
+	RegisterExitHook(func() { panic("broken hook") })
+
+	Fatal("fatal message")
+	if !ran {
+		t.Error("a panicking exit hook prevented earlier hooks from running")
+	}
+}
+
+func TestPanicOnFatal(t *testing.T) {
+	config := DefaultConfig(Test)
+	config.OnFatal = PanicOnFatal
+	initializeToFile(t, config)
+
+	defer func() {
+		if r := recover(); r != "fatal message" {
+			t.Errorf("recover() = %v, want %q", r, "fatal message")
+		}
+	}()
+	Fatal("fatal message")
+	t.Error("Fatal() returned with PanicOnFatal")
+}
+
+func TestGoexitOnFatal(t *testing.T) {
+	config := DefaultConfig(Test)
+	config.OnFatal = GoexitOnFatal
+	initializeToFile(t, config)
+
+	var wg sync.WaitGroup
+	returned := false
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		Fatal("fatal message")
+		returned = true
+	}()
+	wg.Wait()
+
+	if returned {
+		t.Error("Fatal() returned with GoexitOnFatal")
+	}
+}
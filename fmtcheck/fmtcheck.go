@@ -0,0 +1,62 @@
+// Package fmtcheck provides a go/analysis analyzer that reports
+// non-constant format strings passed to the logger's formatting functions
+// (Tracef, Debugf, Infof, Noticef, Warnf, Errorf, Criticalf, DPanicf, Panicf
+// and Fatalf) and to the matching zap.SugaredLogger methods.
+//
+// A format string built from user input can contain verbs that consume or
+// misformat the arguments, and lets the input decide the shape of the
+// message. Constant templates keep user input in the arguments, where
+// Config.Sanitize escapes it.
+package fmtcheck
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/kingrain94/logger/internal/analysisutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+)
+
+// Analyzer reports non-constant format strings passed to the logger's f
+// functions
+var Analyzer = &analysis.Analyzer{
+	Name:     "fmtcheck",
+	Doc:      "check that Debugf, Infof, Warnf, Errorf, Fatalf and the other f functions get constant format strings",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// fmtFuncs are the checked function and method names
+var fmtFuncs = map[string]bool{
+	"Tracef":    true,
+	"Debugf":    true,
+	"Infof":     true,
+	"Noticef":   true,
+	"Warnf":     true,
+	"Errorf":    true,
+	"Criticalf": true,
+	"DPanicf":   true,
+	"Panicf":    true,
+	"Fatalf":    true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	analysisutil.Calls(pass, fmtFuncs, func(call *ast.CallExpr, name string) {
+		if len(call.Args) < 1 {
+			return
+		}
+		template := call.Args[0]
+		if tv, ok := pass.TypesInfo.Types[template]; !ok || tv.Value != nil {
+			return
+		}
+		if len(call.Args) == 1 {
+			pass.Reportf(template.Pos(), "non-constant format string in call to %s; use %s(\"%%s\", %s)",
+				name, name, types.ExprString(template))
+			return
+		}
+		pass.Reportf(template.Pos(), "non-constant format string in call to %s", name)
+	})
+
+	return nil, nil
+}
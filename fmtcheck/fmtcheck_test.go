@@ -0,0 +1,12 @@
+package fmtcheck_test
+
+import (
+	"testing"
+
+	"github.com/kingrain94/logger/fmtcheck"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), fmtcheck.Analyzer, "a")
+}
@@ -0,0 +1,26 @@
+package a
+
+import (
+	"fmt"
+
+	"github.com/kingrain94/logger"
+)
+
+const template = "user %s logged in"
+
+func calls(name string, userID int) {
+	logger.Infof("user %s logged in", name)
+	logger.Infof(template, name)
+	logger.Debugf("no args")
+	logger.Warnf("user "+"%s", name)
+	logger.Infow(name)
+
+	logger.Infof(name)                                   // want `non-constant format string in call to Infof; use Infof\("%s", name\)`
+	logger.Errorf(fmt.Sprintf("user %s: %%d", name), 42) // want `non-constant format string in call to Errorf`
+	logger.Fatalf("user " + name)                        // want `non-constant format string in call to Fatalf`
+
+	sugar := logger.GetSugar()
+	sugar.Infof(template, name)
+	sugar.Infof(name) // want `non-constant format string in call to Infof`
+	sugar.Infow(name)
+}
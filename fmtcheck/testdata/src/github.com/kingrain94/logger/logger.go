@@ -0,0 +1,14 @@
+// Package logger is a minimal stand-in for github.com/kingrain94/logger used
+// by the tests.
+package logger
+
+import "go.uber.org/zap"
+
+func Debugf(template string, args ...interface{})    {}
+func Infof(template string, args ...interface{})     {}
+func Warnf(template string, args ...interface{})     {}
+func Errorf(template string, args ...interface{})    {}
+func Fatalf(template string, args ...interface{})    {}
+func Infow(msg string, keysAndValues ...interface{}) {}
+
+func GetSugar() *zap.SugaredLogger { return &zap.SugaredLogger{} }
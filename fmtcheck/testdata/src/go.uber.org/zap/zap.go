@@ -0,0 +1,7 @@
+// Package zap is a minimal stand-in for go.uber.org/zap used by the tests.
+package zap
+
+type SugaredLogger struct{}
+
+func (s *SugaredLogger) Infof(template string, args ...interface{})     {}
+func (s *SugaredLogger) Infow(msg string, keysAndValues ...interface{}) {}
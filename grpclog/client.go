@@ -0,0 +1,159 @@
+package grpclog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor logs finished outgoing unary calls
+func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		l := o.clientLogger(ctx)
+		if o.skip[method] || l == nil {
+			return err
+		}
+
+		fields := clientFields(cc, method, "unary")
+		fields = append(fields, zap.Int("grpc.request_size", messageSize(req)))
+		if err == nil {
+			fields = append(fields, zap.Int("grpc.response_size", messageSize(reply)))
+		}
+		if o.payloads {
+			fields = append(fields, o.payloadField("grpc.request", req))
+			if err == nil {
+				fields = append(fields, o.payloadField("grpc.response", reply))
+			}
+		}
+		o.logFinished(l, "finished client unary call", start, err, fields)
+		return err
+	}
+}
+
+// StreamClientInterceptor logs outgoing streaming calls once the stream
+// ends, i.e. when receiving returns io.EOF or an error, or for calls
+// without server streaming when the response has been received. A stream
+// the caller abandons by cancelling its context, or letting its deadline
+// expire, is logged with the context's error when gRPC releases it.
+func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		l := o.clientLogger(ctx)
+		if o.skip[method] || l == nil {
+			return cs, err
+		}
+		if err != nil {
+			o.logFinished(l, "finished client streaming call", start, err, clientFields(cc, method, "stream"))
+			return cs, err
+		}
+		s := &clientStream{
+			ClientStream:  cs,
+			serverStreams: desc.ServerStreams,
+			finish: func(err error, counters *streamCounters) {
+				fields := append(clientFields(cc, method, "stream"), counters.fields()...)
+				o.logFinished(l, "finished client streaming call", start, err, fields)
+			},
+		}
+		go s.watch(ctx)
+		return s, nil
+	}
+}
+
+// clientLogger returns the logger for an outgoing call: the configured
+// logger, or the caller's request-scoped logger, with trace fields from ctx
+func (o *options) clientLogger(ctx context.Context) *zap.Logger {
+	if o.logger != nil {
+		return o.logger.With(logger.TraceFields(ctx)...)
+	}
+	return logger.FromContext(ctx)
+}
+
+// clientFields returns the fields identifying an outgoing call
+func clientFields(cc *grpc.ClientConn, fullMethod, kind string) []zap.Field {
+	service, method := splitMethod(fullMethod)
+	fields := []zap.Field{
+		zap.String("grpc.service", service),
+		zap.String("grpc.method", method),
+		zap.String("grpc.kind", kind),
+	}
+	if cc != nil {
+		fields = append(fields, zap.String("grpc.target", cc.Target()))
+	}
+	return fields
+}
+
+// clientStream counts messages and reports the end of the stream once
+type clientStream struct {
+	grpc.ClientStream
+	// serverStreams is false for client-streaming calls, which end with
+	// their single response
+	serverStreams bool
+	finish        func(error, *streamCounters)
+	once          sync.Once
+	mu            sync.Mutex
+	counters      streamCounters
+}
+
+// SendMsg implements grpc.ClientStream
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.counters.recordSent(m)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// RecvMsg implements grpc.ClientStream
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.counters.recordReceived(m)
+		s.mu.Unlock()
+		if !s.serverStreams {
+			s.end(nil)
+		}
+		return nil
+	}
+	s.end(err)
+	return err
+}
+
+// watch ends the stream when gRPC releases it after ctx, the caller's
+// context, is done. Streams that end otherwise are ended by RecvMsg, which
+// knows their status.
+func (s *clientStream) watch(ctx context.Context) {
+	<-s.ClientStream.Context().Done()
+	if err := ctx.Err(); err != nil {
+		s.end(status.FromContextError(err).Err())
+	}
+}
+
+// end reports the end of the stream with the error that ended it, unless
+// it has been reported already
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		finalErr := err
+		if errors.Is(err, io.EOF) {
+			finalErr = nil
+		}
+		s.mu.Lock()
+		counters := s.counters
+		s.mu.Unlock()
+		s.finish(finalErr, &counters)
+	})
+}
@@ -0,0 +1,17 @@
+// Package grpclog provides gRPC interceptors that log calls through the
+// logger package, and an adapter that routes gRPC's own internal logs to it.
+//
+// The server interceptors log the method, peer, status code, duration and
+// message sizes of every call at a level chosen by status code, and store a
+// request-scoped logger in the call context, available through
+// logger.FromContext and the logger Context functions:
+//
+//	server := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(grpclog.UnaryServerInterceptor()),
+//		grpc.ChainStreamInterceptor(grpclog.StreamServerInterceptor()),
+//	)
+//
+// gRPC's internal logs are redirected with:
+//
+//	grpclog.SetLoggerV2(nil)
+package grpclog
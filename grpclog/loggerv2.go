@@ -0,0 +1,167 @@
+package grpclog
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	grpclogger "google.golang.org/grpc/grpclog"
+)
+
+// loggerV2 implements grpclog.LoggerV2 on top of a zap logger
+type loggerV2 struct {
+	// logger is the named logger, or nil to use the global logger
+	logger    *zap.Logger
+	verbosity int
+	// global caches the named copy of the global logger
+	global atomic.Pointer[namedLogger]
+}
+
+// namedLogger is the logger used for gRPC's logs and the logger it was
+// derived from
+type namedLogger struct {
+	base  *zap.Logger
+	named *zap.Logger
+}
+
+// nop is used while there is no global logger
+var nop = zap.NewNop()
+
+// NewLoggerV2 returns a grpclog.LoggerV2 writing gRPC's internal logs to l,
+// named "grpc". If l is nil the package's global logger at the time of each
+// call is used. V(level) reports true for levels up to verbosity.
+func NewLoggerV2(l *zap.Logger, verbosity int) grpclogger.LoggerV2 {
+	g := &loggerV2{verbosity: verbosity}
+	if l != nil {
+		g.logger = grpcLogger(l)
+	}
+	return g
+}
+
+// SetLoggerV2 routes gRPC's internal logs to l, or to the global logger if
+// l is nil, with verbosity 0. It must be called before any other gRPC
+// function.
+func SetLoggerV2(l *zap.Logger) {
+	grpclogger.SetLoggerV2(NewLoggerV2(l, 0))
+}
+
+// grpcLogger returns the logger for gRPC's logs derived from l. gRPC logs
+// through its own wrappers, which are skipped for caller reporting.
+func grpcLogger(l *zap.Logger) *zap.Logger {
+	return l.Named("grpc").WithOptions(zap.AddCallerSkip(2))
+}
+
+// current returns the logger for one call. The named copy of the global
+// logger is only built again when the global logger changes.
+func (g *loggerV2) current() *zap.Logger {
+	if g.logger != nil {
+		return g.logger
+	}
+	base := logger.GetLogger()
+	if base == nil {
+		return nop
+	}
+	if cached := g.global.Load(); cached != nil && cached.base == base {
+		return cached.named
+	}
+	named := grpcLogger(base)
+	g.global.Store(&namedLogger{base: base, named: named})
+	return named
+}
+
+// enabled returns the logger for one call if level is enabled, so that
+// messages are only formatted for entries that may be written
+func (g *loggerV2) enabled(level zapcore.Level) *zap.Logger {
+	if l := g.current(); l.Core().Enabled(level) {
+		return l
+	}
+	return nil
+}
+
+// Info implements grpclog.LoggerV2
+func (g *loggerV2) Info(args ...interface{}) {
+	if l := g.enabled(zapcore.InfoLevel); l != nil {
+		l.Info(fmt.Sprint(args...))
+	}
+}
+
+// Infoln implements grpclog.LoggerV2
+func (g *loggerV2) Infoln(args ...interface{}) {
+	if l := g.enabled(zapcore.InfoLevel); l != nil {
+		l.Info(sprintln(args))
+	}
+}
+
+// Infof implements grpclog.LoggerV2
+func (g *loggerV2) Infof(format string, args ...interface{}) {
+	if l := g.enabled(zapcore.InfoLevel); l != nil {
+		l.Info(fmt.Sprintf(format, args...))
+	}
+}
+
+// Warning implements grpclog.LoggerV2
+func (g *loggerV2) Warning(args ...interface{}) {
+	if l := g.enabled(zapcore.WarnLevel); l != nil {
+		l.Warn(fmt.Sprint(args...))
+	}
+}
+
+// Warningln implements grpclog.LoggerV2
+func (g *loggerV2) Warningln(args ...interface{}) {
+	if l := g.enabled(zapcore.WarnLevel); l != nil {
+		l.Warn(sprintln(args))
+	}
+}
+
+// Warningf implements grpclog.LoggerV2
+func (g *loggerV2) Warningf(format string, args ...interface{}) {
+	if l := g.enabled(zapcore.WarnLevel); l != nil {
+		l.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
+// Error implements grpclog.LoggerV2
+func (g *loggerV2) Error(args ...interface{}) {
+	if l := g.enabled(zapcore.ErrorLevel); l != nil {
+		l.Error(fmt.Sprint(args...))
+	}
+}
+
+// Errorln implements grpclog.LoggerV2
+func (g *loggerV2) Errorln(args ...interface{}) {
+	if l := g.enabled(zapcore.ErrorLevel); l != nil {
+		l.Error(sprintln(args))
+	}
+}
+
+// Errorf implements grpclog.LoggerV2
+func (g *loggerV2) Errorf(format string, args ...interface{}) {
+	if l := g.enabled(zapcore.ErrorLevel); l != nil {
+		l.Error(fmt.Sprintf(format, args...))
+	}
+}
+
+// Fatal implements grpclog.LoggerV2. Fatal entries are always logged, as
+// they exit.
+func (g *loggerV2) Fatal(args ...interface{}) { g.current().Fatal(fmt.Sprint(args...)) }
+
+// Fatalln implements grpclog.LoggerV2
+func (g *loggerV2) Fatalln(args ...interface{}) { g.current().Fatal(sprintln(args)) }
+
+// Fatalf implements grpclog.LoggerV2
+func (g *loggerV2) Fatalf(format string, args ...interface{}) {
+	g.current().Fatal(fmt.Sprintf(format, args...))
+}
+
+// V implements grpclog.LoggerV2
+func (g *loggerV2) V(level int) bool {
+	return level <= g.verbosity
+}
+
+// sprintln formats like fmt.Sprintln without the trailing newline
+func sprintln(args []interface{}) string {
+	s := fmt.Sprintln(args...)
+	return s[:len(s)-1]
+}
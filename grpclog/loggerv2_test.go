@@ -0,0 +1,97 @@
+package grpclog
+
+import (
+	"testing"
+
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggerV2(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	g := NewLoggerV2(zap.New(core), 1)
+
+	g.Info("info ", 1)
+	g.Infoln("info", 2)
+	g.Infof("info %d", 3)
+	g.Warning("warning")
+	g.Warningln("warning", "ln")
+	g.Warningf("warning %s", "f")
+	g.Error("error")
+	g.Errorln("error", "ln")
+	g.Errorf("error %s", "f")
+
+	expected := []struct {
+		level zapcore.Level
+		msg   string
+	}{
+		{zapcore.InfoLevel, "info 1"},
+		{zapcore.InfoLevel, "info 2"},
+		{zapcore.InfoLevel, "info 3"},
+		{zapcore.WarnLevel, "warning"},
+		{zapcore.WarnLevel, "warning ln"},
+		{zapcore.WarnLevel, "warning f"},
+		{zapcore.ErrorLevel, "error"},
+		{zapcore.ErrorLevel, "error ln"},
+		{zapcore.ErrorLevel, "error f"},
+	}
+	entries := logs.All()
+	if len(entries) != len(expected) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(expected))
+	}
+	for i, want := range expected {
+		if entries[i].Level != want.level || entries[i].Message != want.msg || entries[i].LoggerName != "grpc" {
+			t.Errorf("entry %d = %v %q %q, want %v %q grpc", i, entries[i].Level, entries[i].Message, entries[i].LoggerName, want.level, want.msg)
+		}
+	}
+
+	if !g.V(1) || g.V(2) {
+		t.Errorf("V() does not honor verbosity 1")
+	}
+}
+
+func TestLoggerV2Disabled(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	g := NewLoggerV2(zap.New(core), 0)
+
+	formatted := 0
+	arg := stringer(func() string {
+		formatted++
+		return "arg"
+	})
+	g.Infof("dialing %s", arg)
+	g.Info(arg)
+	if formatted != 0 || logs.Len() != 0 {
+		t.Errorf("disabled Info formatted %d times, logged %d entries", formatted, logs.Len())
+	}
+	if allocs := testing.AllocsPerRun(100, func() { g.Infof("dialing %s", "target") }); allocs != 0 {
+		t.Errorf("disabled Infof: %v allocations, want 0", allocs)
+	}
+}
+
+func TestLoggerV2Global(t *testing.T) {
+	defer logger.Initialize(logger.DefaultConfig(logger.Test))
+	g := NewLoggerV2(nil, 0).(*loggerV2)
+
+	var previous *zap.Logger
+	for i, env := range []logger.Environment{logger.Test, logger.Staging} {
+		if err := logger.Initialize(logger.DefaultConfig(env)); err != nil {
+			t.Fatal(err)
+		}
+		first := g.current()
+		if first.Name() != "grpc" || first == previous {
+			t.Errorf("initialization %d: got logger %q, want a new one named grpc", i, first.Name())
+		}
+		if g.current() != first {
+			t.Errorf("initialization %d: named logger rebuilt for the same global logger", i)
+		}
+		previous = first
+	}
+}
+
+// stringer counts formatting through fmt.Stringer
+type stringer func() string
+
+func (s stringer) String() string { return s() }
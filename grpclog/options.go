@@ -0,0 +1,144 @@
+package grpclog
+
+import (
+	"encoding/json"
+
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// redactedValue replaces redacted payload fields
+const redactedValue = "[REDACTED]"
+
+// Option configures the interceptors
+type Option func(*options)
+
+type options struct {
+	logger   *zap.Logger
+	levels   func(codes.Code) zapcore.Level
+	skip     map[string]bool
+	payloads bool
+	redact   map[string]bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		levels: DefaultCodeToLevel,
+		skip:   map[string]bool{},
+		redact: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithLogger sets the logger used by the interceptors. By default the
+// package's global logger at the time of each call is used.
+func WithLogger(l *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithLevels sets the function choosing the level of a finished call from
+// its status code
+func WithLevels(levels func(codes.Code) zapcore.Level) Option {
+	return func(o *options) {
+		o.levels = levels
+	}
+}
+
+// WithSkipMethods disables logging for the given full method names, e.g.
+// "/grpc.health.v1.Health/Check". The request-scoped logger is still
+// available to the handler.
+func WithSkipMethods(fullMethods ...string) Option {
+	return func(o *options) {
+		for _, m := range fullMethods {
+			o.skip[m] = true
+		}
+	}
+}
+
+// WithPayloads logs request and response messages of unary calls as JSON.
+// Fields with the given proto names are replaced by "[REDACTED]" at any
+// depth.
+func WithPayloads(redactedFields ...string) Option {
+	return func(o *options) {
+		o.payloads = true
+		for _, f := range redactedFields {
+			o.redact[f] = true
+		}
+	}
+}
+
+// DefaultCodeToLevel logs client errors at info, conditions worth attention
+// at warn and server faults at error
+func DefaultCodeToLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound,
+		codes.AlreadyExists, codes.Unauthenticated:
+		return zapcore.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// baseLogger returns the configured logger or the current global logger
+func (o *options) baseLogger() *zap.Logger {
+	if o.logger != nil {
+		return o.logger
+	}
+	return logger.GetLogger()
+}
+
+// messageSize returns the encoded size of a protobuf message, or -1
+func messageSize(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return -1
+}
+
+// payloadField renders a message as JSON with redacted fields
+func (o *options) payloadField(key string, msg interface{}) zap.Field {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return zap.Skip()
+	}
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return zap.String(key+"_error", err.Error())
+	}
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return zap.String(key+"_error", err.Error())
+	}
+	return zap.Any(key, o.redactValue(payload))
+}
+
+// redactValue replaces redacted fields in a decoded JSON value
+func (o *options) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			if o.redact[key] {
+				v[key] = redactedValue
+			} else {
+				v[key] = o.redactValue(elem)
+			}
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = o.redactValue(elem)
+		}
+	}
+	return value
+}
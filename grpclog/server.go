@@ -0,0 +1,164 @@
+package grpclog
+
+import (
+	"context"
+	"time"
+
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor logs finished unary calls and stores a
+// request-scoped logger in the handler's context
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		base := o.baseLogger()
+		if base == nil {
+			return handler(ctx, req)
+		}
+		ctx = logger.NewContext(ctx, base.With(callFields(ctx, info.FullMethod, "unary")...))
+
+		resp, err := handler(ctx, req)
+		if o.skip[info.FullMethod] {
+			return resp, err
+		}
+
+		fields := []zap.Field{
+			zap.Int("grpc.request_size", messageSize(req)),
+			zap.Int("grpc.response_size", messageSize(resp)),
+		}
+		if o.payloads {
+			fields = append(fields, o.payloadField("grpc.request", req), o.payloadField("grpc.response", resp))
+		}
+		o.logFinished(logger.FromContext(ctx), "finished unary call", start, err, fields)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs finished streaming calls with the number and
+// size of messages exchanged, and stores a request-scoped logger in the
+// stream's context
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		base := o.baseLogger()
+		if base == nil {
+			return handler(srv, ss)
+		}
+		ctx := ss.Context()
+		ctx = logger.NewContext(ctx, base.With(callFields(ctx, info.FullMethod, "stream")...))
+		stream := &serverStream{ServerStream: ss, ctx: ctx}
+
+		err := handler(srv, stream)
+		if o.skip[info.FullMethod] {
+			return err
+		}
+		o.logFinished(logger.FromContext(ctx), "finished streaming call", start, err, stream.counters.fields())
+		return err
+	}
+}
+
+// callFields returns the fields identifying a call
+func callFields(ctx context.Context, fullMethod, kind string) []zap.Field {
+	service, method := splitMethod(fullMethod)
+	fields := []zap.Field{
+		zap.String("grpc.service", service),
+		zap.String("grpc.method", method),
+		zap.String("grpc.kind", kind),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer.address", p.Addr.String()))
+	}
+	return fields
+}
+
+// logFinished logs the end of a call at the level for its status code
+func (o *options) logFinished(l *zap.Logger, msg string, start time.Time, err error, fields []zap.Field) {
+	code := status.Code(err)
+	fields = append(fields,
+		zap.String("grpc.code", code.String()),
+		zap.Duration("duration", time.Since(start)),
+	)
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	l.Log(o.levels(code), msg, fields...)
+}
+
+// splitMethod splits "/package.Service/Method" into service and method
+func splitMethod(fullMethod string) (service, method string) {
+	if len(fullMethod) > 0 && fullMethod[0] == '/' {
+		fullMethod = fullMethod[1:]
+	}
+	for i := len(fullMethod) - 1; i >= 0; i-- {
+		if fullMethod[i] == '/' {
+			return fullMethod[:i], fullMethod[i+1:]
+		}
+	}
+	return "unknown", fullMethod
+}
+
+// streamCounters counts messages and bytes on a stream
+type streamCounters struct {
+	sent, received           int
+	sentBytes, receivedBytes int
+}
+
+func (c *streamCounters) fields() []zap.Field {
+	return []zap.Field{
+		zap.Int("grpc.messages_sent", c.sent),
+		zap.Int("grpc.messages_received", c.received),
+		zap.Int("grpc.bytes_sent", c.sentBytes),
+		zap.Int("grpc.bytes_received", c.receivedBytes),
+	}
+}
+
+func (c *streamCounters) recordSent(msg interface{}) {
+	c.sent++
+	if n := messageSize(msg); n > 0 {
+		c.sentBytes += n
+	}
+}
+
+func (c *streamCounters) recordReceived(msg interface{}) {
+	c.received++
+	if n := messageSize(msg); n > 0 {
+		c.receivedBytes += n
+	}
+}
+
+// serverStream carries the request-scoped context and counts messages
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	counters streamCounters
+}
+
+// Context returns the context with the request-scoped logger
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg implements grpc.ServerStream
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.counters.recordSent(m)
+	}
+	return err
+}
+
+// RecvMsg implements grpc.ServerStream
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.counters.recordReceived(m)
+	}
+	return err
+}
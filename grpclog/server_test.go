@@ -0,0 +1,299 @@
+package grpclog
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// contextCheckingHealth wraps the health service and logs through the
+// request-scoped logger
+type contextCheckingHealth struct {
+	*health.Server
+}
+
+func (h *contextCheckingHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	logger.FromContext(ctx).Info("checking health")
+	return h.Server.Check(ctx, req)
+}
+
+// collectDesc describes a client-streaming service that counts the
+// requests it receives; there is no such method in the health service
+var collectDesc = grpc.ServiceDesc{
+	ServiceName: "test.Collector",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Collect",
+		ClientStreams: true,
+		Handler: func(_ interface{}, stream grpc.ServerStream) error {
+			for {
+				if err := stream.RecvMsg(&healthpb.HealthCheckRequest{}); err == io.EOF {
+					return stream.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+				} else if err != nil {
+					return err
+				}
+			}
+		},
+	}},
+}
+
+// startServer serves the health service over an in-memory connection and
+// returns a client whose calls go through the client interceptors
+func startServer(t *testing.T, serverOpts, clientOpts []Option) (healthpb.HealthClient, *health.Server) {
+	t.Helper()
+	conn, healthServer := dialServer(t, serverOpts, clientOpts)
+	return healthpb.NewHealthClient(conn), healthServer
+}
+
+// dialServer serves the health and collector services over an in-memory
+// connection and returns a connection with the client interceptors
+func dialServer(t *testing.T, serverOpts, clientOpts []Option) (*grpc.ClientConn, *health.Server) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("inventory", healthpb.HealthCheckResponse_SERVING)
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(serverOpts...)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(serverOpts...)),
+	)
+	healthpb.RegisterHealthServer(srv, &contextCheckingHealth{Server: healthServer})
+	srv.RegisterService(&collectDesc, nil)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(clientOpts...)),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor(clientOpts...)),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, healthServer
+}
+
+func fieldMap(entry observer.LoggedEntry) map[string]interface{} {
+	return entry.ContextMap()
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	client, _ := startServer(t, []Option{WithLogger(zap.New(core))}, []Option{WithLogger(zap.NewNop())})
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "inventory"}); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Check() code = %v, want NotFound", status.Code(err))
+	}
+
+	handlerLogs := logs.FilterMessage("checking health").All()
+	if len(handlerLogs) != 2 {
+		t.Fatalf("handler logged %d entries through the context logger, want 2", len(handlerLogs))
+	}
+	if fields := fieldMap(handlerLogs[0]); fields["grpc.method"] != "Check" || fields["grpc.service"] != "grpc.health.v1.Health" {
+		t.Errorf("request-scoped logger fields = %v", fields)
+	}
+
+	finished := logs.FilterMessage("finished unary call").All()
+	if len(finished) != 2 {
+		t.Fatalf("got %d finished entries, want 2", len(finished))
+	}
+	ok := fieldMap(finished[0])
+	if finished[0].Level != zapcore.InfoLevel || ok["grpc.code"] != "OK" {
+		t.Errorf("OK call entry = %v %v", finished[0].Level, ok)
+	}
+	if ok["grpc.request_size"] != int64(11) || ok["grpc.response_size"] != int64(2) {
+		t.Errorf("message sizes = %v %v", ok["grpc.request_size"], ok["grpc.response_size"])
+	}
+	if ok["peer.address"] == nil {
+		t.Error("finished entry has no peer.address")
+	}
+	notFound := fieldMap(finished[1])
+	if notFound["grpc.code"] != "NotFound" || notFound["error"] == nil {
+		t.Errorf("NotFound call entry = %v", notFound)
+	}
+}
+
+func TestServerLevels(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	levels := func(code codes.Code) zapcore.Level {
+		if code == codes.NotFound {
+			return zapcore.ErrorLevel
+		}
+		return zapcore.DebugLevel
+	}
+	client, _ := startServer(t, []Option{WithLogger(zap.New(core)), WithLevels(levels)}, []Option{WithLogger(zap.NewNop())})
+
+	client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+	finished := logs.FilterMessage("finished unary call").All()
+	if len(finished) != 1 || finished[0].Level != zapcore.ErrorLevel {
+		t.Errorf("finished entries = %v, want one at error", finished)
+	}
+}
+
+func TestSkipMethods(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	opts := []Option{WithLogger(zap.New(core)), WithSkipMethods(healthpb.Health_Check_FullMethodName)}
+	client, _ := startServer(t, opts, opts)
+
+	client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "inventory"})
+	if n := logs.FilterMessageSnippet("finished").Len(); n != 0 {
+		t.Errorf("got %d finished entries for a skipped method, want 0", n)
+	}
+	if n := logs.FilterMessage("checking health").Len(); n != 1 {
+		t.Errorf("handler logged %d entries, want 1", n)
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	client, _ := startServer(t, []Option{WithLogger(zap.New(core)), WithPayloads("service")}, []Option{WithLogger(zap.NewNop())})
+
+	client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "inventory"})
+	finished := logs.FilterMessage("finished unary call").All()
+	if len(finished) != 1 {
+		t.Fatalf("got %d finished entries, want 1", len(finished))
+	}
+	fields := fieldMap(finished[0])
+	request, _ := fields["grpc.request"].(map[string]interface{})
+	if request["service"] != redactedValue {
+		t.Errorf("grpc.request = %v, want service redacted", fields["grpc.request"])
+	}
+	response, _ := fields["grpc.response"].(map[string]interface{})
+	if response["status"] != "SERVING" {
+		t.Errorf("grpc.response = %v", fields["grpc.response"])
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	opts := []Option{WithLogger(zap.New(core))}
+	client, healthServer := startServer(t, opts, opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "inventory"})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	healthServer.SetServingStatus("inventory", healthpb.HealthCheckResponse_NOT_SERVING)
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatalf("Recv() after cancel error = %v, want Canceled", err)
+	}
+
+	clientEntries := logs.FilterMessage("finished client streaming call").All()
+	if len(clientEntries) != 1 {
+		t.Fatalf("got %d client stream entries, want 1", len(clientEntries))
+	}
+	fields := fieldMap(clientEntries[0])
+	if received, _ := fields["grpc.messages_received"].(int64); received < 2 {
+		t.Errorf("grpc.messages_received = %v, want at least 2", fields["grpc.messages_received"])
+	}
+	if fields["grpc.messages_sent"] != int64(1) || fields["grpc.target"] != "passthrough:///bufnet" {
+		t.Errorf("client stream entry = %v", fields)
+	}
+}
+
+func TestClientStreamingInterceptors(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	opts := []Option{WithLogger(zap.New(core))}
+	conn, _ := dialServer(t, opts, opts)
+
+	stream, err := conn.NewStream(context.Background(), &collectDesc.Streams[0], "/test.Collector/Collect")
+	if err != nil {
+		t.Fatalf("NewStream() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.SendMsg(&healthpb.HealthCheckRequest{Service: "inventory"}); err != nil {
+			t.Fatalf("SendMsg() error = %v", err)
+		}
+	}
+	// CloseAndRecv of generated client-streaming clients
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend() error = %v", err)
+	}
+	if err := stream.RecvMsg(&healthpb.HealthCheckResponse{}); err != nil {
+		t.Fatalf("RecvMsg() error = %v", err)
+	}
+
+	clientEntries := logs.FilterMessage("finished client streaming call").All()
+	if len(clientEntries) != 1 {
+		t.Fatalf("got %d client stream entries, want 1", len(clientEntries))
+	}
+	fields := fieldMap(clientEntries[0])
+	if fields["grpc.messages_sent"] != int64(3) || fields["grpc.messages_received"] != int64(1) || fields["grpc.code"] != "OK" {
+		t.Errorf("client stream entry = %v", fields)
+	}
+}
+
+func TestAbandonedClientStream(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	conn, _ := dialServer(t, nil, []Option{WithLogger(zap.New(core))})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{Service: "inventory"})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	// The caller stops watching without receiving the end of the stream
+	cancel()
+
+	var entries []observer.LoggedEntry
+	for deadline := time.Now().Add(5 * time.Second); len(entries) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		entries = logs.FilterMessage("finished client streaming call").All()
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d client stream entries, want 1", len(entries))
+	}
+	fields := fieldMap(entries[0])
+	if fields["grpc.code"] != codes.Canceled.String() || fields["grpc.messages_received"] != int64(1) {
+		t.Errorf("client stream entry = %v", fields)
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	tests := []struct {
+		fullMethod, service, method string
+	}{
+		{"/grpc.health.v1.Health/Check", "grpc.health.v1.Health", "Check"},
+		{"pkg.Service/Method", "pkg.Service", "Method"},
+		{"Method", "unknown", "Method"},
+	}
+	for _, tt := range tests {
+		service, method := splitMethod(tt.fullMethod)
+		if service != tt.service || method != tt.method {
+			t.Errorf("splitMethod(%q) = %q, %q", tt.fullMethod, service, method)
+		}
+	}
+}
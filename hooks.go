@@ -0,0 +1,281 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultHookQueueSize bounds the entries waiting for an asynchronous hook
+const DefaultHookQueueSize = 1024
+
+// Hook is called for entries logged at or above the level it was added
+// with, after level filtering and sampling. Fields include those added
+// with With. Returned errors are reported as internal errors.
+type Hook func(zapcore.Entry, []zapcore.Field) error
+
+// HookOption configures a hook added with AddHook
+type HookOption func(*hook)
+
+// HookAsync runs the hook on a pool of workers instead of in the logging
+// goroutine. Entries are queued, up to queueSize or DefaultHookQueueSize if
+// zero; entries logged while the queue is full are dropped and reported as
+// internal errors. Asynchronous hooks may not see entries logged just
+// before the program exits. They receive a copy of the fields: values that
+// may refer to the caller's memory are copied, with objects and arrays
+// encoded as maps and slices, and lazy values computed.
+func HookAsync(workers, queueSize int) HookOption {
+	return func(h *hook) {
+		if workers <= 0 {
+			workers = 1
+		}
+		if queueSize <= 0 {
+			queueSize = DefaultHookQueueSize
+		}
+		h.workers = workers
+		h.queueSize = queueSize
+	}
+}
+
+// hook is a registered hook. Hooks are compared by pointer so that the same
+// function can be added more than once.
+type hook struct {
+	level     zapcore.Level
+	fn        Hook
+	workers   int
+	queueSize int
+
+	queue chan hookJob
+	stop  chan struct{}
+}
+
+// hookJob is an entry waiting for an asynchronous hook
+type hookJob struct {
+	ent         zapcore.Entry
+	fields      []zapcore.Field
+	errorOutput zapcore.WriteSyncer
+}
+
+var (
+	hooksMu sync.Mutex
+	// hooks is replaced rather than modified so that logging can read it
+	// without locking
+	hooks atomic.Pointer[[]*hook]
+)
+
+// AddHook adds fn to be called for every entry at or above minLevel that
+// passes level filtering and sampling, in any logger built by Initialize.
+// Hooks run synchronously unless HookAsync is given, and a panicking hook
+// is reported as an internal error. The returned function removes the hook.
+func AddHook(minLevel zapcore.Level, fn Hook, opts ...HookOption) func() {
+	h := &hook{level: minLevel, fn: fn}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.workers > 0 {
+		h.queue = make(chan hookJob, h.queueSize)
+		h.stop = make(chan struct{})
+		for i := 0; i < h.workers; i++ {
+			go h.work()
+		}
+	}
+
+	hooksMu.Lock()
+	current := loadHooks()
+	updated := make([]*hook, 0, len(current)+1)
+	updated = append(append(updated, current...), h)
+	hooks.Store(&updated)
+	hooksMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			hooksMu.Lock()
+			current := loadHooks()
+			updated := make([]*hook, 0, len(current))
+			for _, other := range current {
+				if other != h {
+					updated = append(updated, other)
+				}
+			}
+			hooks.Store(&updated)
+			hooksMu.Unlock()
+
+			if h.stop != nil {
+				close(h.stop)
+			}
+		})
+	}
+}
+
+// loadHooks returns the registered hooks
+func loadHooks() []*hook {
+	if p := hooks.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// call runs the hook, returning a panic as an error
+func (h *hook) call(ent zapcore.Entry, fields []zapcore.Field) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("hook panicked: %v", r)
+		}
+	}()
+	if err := h.fn(ent, fields); err != nil {
+		return fmt.Errorf("hook failed: %w", err)
+	}
+	return nil
+}
+
+// enqueue hands an entry to the workers of an asynchronous hook
+func (h *hook) enqueue(job hookJob) {
+	select {
+	case <-h.stop:
+	case h.queue <- job:
+	default:
+		reportHookError(job.errorOutput, fmt.Errorf("hook queue full, entry dropped: %s", job.ent.Message))
+	}
+}
+
+// work runs an asynchronous hook until it is removed
+func (h *hook) work() {
+	for {
+		select {
+		case <-h.stop:
+			return
+		case job := <-h.queue:
+			if err := h.call(job.ent, job.fields); err != nil {
+				reportHookError(job.errorOutput, err)
+			}
+		}
+	}
+}
+
+// reportHookError writes an error from an asynchronous hook to the error
+// output of the logger that logged the entry, like zap does for failed
+// writes
+func reportHookError(errorOutput zapcore.WriteSyncer, err error) {
+	if errorOutput == nil {
+		return
+	}
+	fmt.Fprintf(errorOutput, "%v hook error: %v\n", time.Now(), err)
+	_ = errorOutput.Sync()
+}
+
+// hookCore runs the registered hooks for entries passed to the wrapped
+// core. It is placed inside the level filter and sampling, so hooks only
+// see entries at enabled levels that are written.
+type hookCore struct {
+	zapcore.Core
+	errorOutput zapcore.WriteSyncer
+	fields      []zapcore.Field
+}
+
+func newHookCore(core zapcore.Core, errorOutput zapcore.WriteSyncer) zapcore.Core {
+	return &hookCore{Core: core, errorOutput: errorOutput}
+}
+
+// With implements zapcore.Core
+func (c *hookCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &hookCore{Core: c.Core.With(fields), errorOutput: c.errorOutput}
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return clone
+}
+
+// Check implements zapcore.Core
+func (c *hookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	ce = c.Core.Check(ent, ce)
+	for _, h := range loadHooks() {
+		if levelAtLeast(ent.Level, h.level) {
+			return ce.AddCore(ent, hookRunner{c})
+		}
+	}
+	return ce
+}
+
+// Write implements zapcore.Core for entries written directly, such as those
+// flushed by a Scope
+func (c *hookCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	err := c.Core.Write(ent, fields)
+	return errors.Join(err, hookRunner{c}.Write(ent, fields))
+}
+
+// hookRunner is added to checked entries by hookCore so that writing an
+// entry runs the hooks without writing it to the wrapped core again
+type hookRunner struct {
+	*hookCore
+}
+
+// Write implements zapcore.Core. Errors of synchronous hooks are returned
+// so that zap reports them to the error output.
+func (r hookRunner) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := fields
+	if len(r.fields) > 0 {
+		all = make([]zapcore.Field, 0, len(r.fields)+len(fields))
+		all = append(append(all, r.fields...), fields...)
+	}
+
+	var errs []error
+	// snapshot holds the fields for asynchronous hooks, which run after
+	// the caller may have changed the values they refer to
+	var snapshot []zapcore.Field
+	for _, h := range loadHooks() {
+		if !levelAtLeast(ent.Level, h.level) {
+			continue
+		}
+		if h.queue != nil {
+			if snapshot == nil {
+				snapshot = snapshotFields(all)
+			}
+			h.enqueue(hookJob{
+				ent:         ent,
+				fields:      snapshot,
+				errorOutput: r.errorOutput,
+			})
+		} else if err := h.call(ent, all); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotFields returns a copy of fields that does not refer to memory of
+// the caller, for fields used after Write returns. Values that may, such as
+// objects, arrays, byte slices and lazy values, are encoded as by a
+// zapcore.MapObjectEncoder. Errors are kept so that they can be inspected.
+func snapshotFields(fields []zapcore.Field) []zapcore.Field {
+	snapshot := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		switch f.Type {
+		case zapcore.BinaryType:
+			snapshot = append(snapshot, zap.Binary(f.Key, bytes.Clone(f.Interface.([]byte))))
+		case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType,
+			zapcore.ByteStringType, zapcore.StringerType, zapcore.ReflectType:
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+			resolveLazy(enc.Fields)
+			// Inlined objects may add several keys
+			keys := make([]string, 0, len(enc.Fields))
+			for key := range enc.Fields {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				snapshot = append(snapshot, zap.Any(key, enc.Fields[key]))
+			}
+		default:
+			snapshot = append(snapshot, f)
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAddHook(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	var got []string
+	remove := AddHook(zapcore.WarnLevel, func(ent zapcore.Entry, fields []zapcore.Field) error {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		got = append(got, LevelName(ent.Level)+":"+ent.Message+":"+enc.Fields["component"].(string))
+		return nil
+	})
+
+	l := With(zap.String("component", "db"))
+	l.Info("below hook level")
+	l.Warn("warned")
+	l.Log(CriticalLevel, "critical")
+	SetLevel(zapcore.ErrorLevel)
+	l.Warn("filtered")
+	SetLevel(zapcore.InfoLevel)
+
+	remove()
+	remove()
+	l.Error("after remove")
+
+	want := []string{"warn:warned:db", "critical:critical:db"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("hook calls = %v, want %v", got, want)
+	}
+	if lines := read(); len(lines) != 4 {
+		t.Errorf("got %d output lines, want 4", len(lines))
+	}
+}
+
+func TestAddHookErrors(t *testing.T) {
+	errPath := filepath.Join(t.TempDir(), "errors.log")
+	config := DefaultConfig(Staging)
+	config.ErrorOutputPaths = []string{errPath}
+	read := initializeToFile(t, config)
+
+	defer AddHook(zapcore.ErrorLevel, func(zapcore.Entry, []zapcore.Field) error {
+		return errors.New("webhook unavailable")
+	})()
+	defer AddHook(zapcore.ErrorLevel, func(zapcore.Entry, []zapcore.Field) error {
+		panic("broken hook")
+	})()
+
+	before := InternalErrors()
+	Error("failed")
+
+	if lines := read(); len(lines) != 1 {
+		t.Errorf("got %d output lines, want 1", len(lines))
+	}
+	if got := InternalErrors() - before; got != 1 {
+		t.Errorf("internal errors = %d, want 1", got)
+	}
+	data, err := os.ReadFile(errPath)
+	if err != nil {
+		t.Fatalf("reading error output: %v", err)
+	}
+	if !strings.Contains(string(data), "webhook unavailable") || !strings.Contains(string(data), "broken hook") {
+		t.Errorf("error output = %q, want both hook errors", data)
+	}
+}
+
+func TestAddHookAsync(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Staging))
+
+	var mu sync.Mutex
+	var got []string
+	done := make(chan struct{}, 10)
+	remove := AddHook(zapcore.InfoLevel, func(ent zapcore.Entry, _ []zapcore.Field) error {
+		mu.Lock()
+		got = append(got, ent.Message)
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	}, HookAsync(2, 10))
+	defer remove()
+
+	Info("one")
+	Info("two")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the hook")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Errorf("hook calls = %v, want 2", got)
+	}
+}
+
+func TestAddHookAsyncCopiesFields(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Staging))
+
+	got := make(chan map[string]interface{}, 1)
+	release := make(chan struct{})
+	remove := AddHook(zapcore.InfoLevel, func(_ zapcore.Entry, fields []zapcore.Field) error {
+		<-release
+		enc := zapcore.NewMapObjectEncoder()
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		got <- enc.Fields
+		return nil
+	}, HookAsync(1, 10))
+	defer remove()
+
+	// The hook runs after the caller has changed every value
+	roles := []string{"admin"}
+	body := []byte("before")
+	d := &diff{changed: 1}
+	Info("changed", zap.Strings("roles", roles), zap.Binary("body", body), zap.Object("diff", d),
+		Lazy("lazy", func() interface{} { return d.changed }))
+	roles[0], body[0], d.changed = "guest", 'B', 2
+	close(release)
+
+	var fields map[string]interface{}
+	select {
+	case fields = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the hook")
+	}
+	if roles, _ := fields["roles"].([]interface{}); len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("roles = %v, want [admin]", fields["roles"])
+	}
+	if body, _ := fields["body"].([]byte); string(body) != "before" {
+		t.Errorf("body = %q, want before", fields["body"])
+	}
+	if d, _ := fields["diff"].(map[string]interface{}); d["changed"] != 1 {
+		t.Errorf("diff = %v, want changed 1", fields["diff"])
+	}
+	if fields["lazy"] != int64(1) {
+		t.Errorf("lazy = %#v, want 1", fields["lazy"])
+	}
+}
+
+func TestAddHookScopeFlush(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Production))
+
+	var got []string
+	defer AddHook(zapcore.DebugLevel, func(ent zapcore.Entry, _ []zapcore.Field) error {
+		got = append(got, ent.Message)
+		return nil
+	})()
+
+	ctx, scope := NewScope(context.Background())
+	DebugContext(ctx, "buffered")
+	ErrorContext(ctx, "failed")
+	scope.End()
+
+	if strings.Join(got, ",") != "buffered,failed" {
+		t.Errorf("hook calls = %v, want the flushed entry and the error", got)
+	}
+}
@@ -0,0 +1,75 @@
+// Package analysisutil holds what the fmtcheck and kvcheck analyzers share:
+// finding the calls to the logger's functions and the matching
+// zap.SugaredLogger methods.
+package analysisutil
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Import paths of the checked packages
+const (
+	LoggerPath  = "github.com/kingrain94/logger"
+	ZapPath     = "go.uber.org/zap"
+	ZapcorePath = "go.uber.org/zap/zapcore"
+)
+
+// Calls calls fn for every call in the package of pass to one of the
+// logger's functions, or of the zap.SugaredLogger methods, whose name is in
+// names. The analyzer must require inspect.Analyzer.
+func Calls(pass *analysis.Pass, names map[string]bool, fn func(call *ast.CallExpr, name string)) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		if name, ok := loggerCall(pass, call, names); ok {
+			fn(call, name)
+		}
+	})
+}
+
+// loggerCall returns the name of the function or method called by call if
+// it is one of names of the logger or of zap.SugaredLogger
+func loggerCall(pass *analysis.Pass, call *ast.CallExpr, names map[string]bool) (string, bool) {
+	var ident *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return "", false
+	}
+	if !names[ident.Name] {
+		return "", false
+	}
+
+	fn, ok := pass.TypesInfo.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return "", false
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ident.Name, fn.Pkg().Path() == LoggerPath
+	}
+	return ident.Name, fn.Pkg().Path() == ZapPath && IsNamed(recv.Type(), ZapPath, "SugaredLogger")
+}
+
+// IsNamed reports whether t, or the type it points to, is the named type or
+// type alias path.name
+func IsNamed(t types.Type, path, name string) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(interface{ Obj() *types.TypeName })
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name
+}
@@ -0,0 +1,44 @@
+// Package filter holds what the logger and the logview and logquery
+// commands share to select entries: the order of the levels and the syntax
+// of time bounds.
+package filter
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Custom levels, exported by the logger package as TraceLevel, NoticeLevel
+// and CriticalLevel. Notice and Critical live above zap's range, see
+// LevelRank.
+const (
+	TraceLevel    = zapcore.DebugLevel - 1
+	NoticeLevel   = zapcore.InvalidLevel + 1
+	CriticalLevel = zapcore.InvalidLevel + 2
+)
+
+// LevelRank returns the position of a level in the severity order. zap's
+// levels are spread out so that the custom levels fit in between.
+func LevelRank(l zapcore.Level) int {
+	switch l {
+	case NoticeLevel:
+		return int(zapcore.InfoLevel)*2 + 1
+	case CriticalLevel:
+		return int(zapcore.ErrorLevel)*2 + 1
+	default:
+		return int(l) * 2
+	}
+}
+
+// ParseTime parses an RFC 3339 time, or a duration meaning that long
+// before now. The empty string is the zero time.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLevelRank(t *testing.T) {
+	ordered := []zapcore.Level{
+		TraceLevel,
+		zapcore.DebugLevel,
+		zapcore.InfoLevel,
+		NoticeLevel,
+		zapcore.WarnLevel,
+		zapcore.ErrorLevel,
+		CriticalLevel,
+		zapcore.DPanicLevel,
+		zapcore.PanicLevel,
+		zapcore.FatalLevel,
+	}
+	for i := 1; i < len(ordered); i++ {
+		if LevelRank(ordered[i]) <= LevelRank(ordered[i-1]) {
+			t.Errorf("%v should rank above %v", ordered[i], ordered[i-1])
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	got, err := ParseTime("15m", now)
+	if err != nil || !got.Equal(now.Add(-15*time.Minute)) {
+		t.Errorf("ParseTime(15m) = %v, %v", got, err)
+	}
+	got, err = ParseTime("2024-01-01T10:00:00.5Z", now)
+	if err != nil || !got.Equal(time.Date(2024, 1, 1, 10, 0, 0, 5e8, time.UTC)) {
+		t.Errorf("ParseTime(RFC 3339) = %v, %v", got, err)
+	}
+	if got, err := ParseTime("", now); err != nil || !got.IsZero() {
+		t.Errorf("ParseTime() = %v, %v", got, err)
+	}
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Error("ParseTime(yesterday) succeeded")
+	}
+}
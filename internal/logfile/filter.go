@@ -0,0 +1,139 @@
+package logfile
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kingrain94/logger/internal/filter"
+	"go.uber.org/zap/zapcore"
+)
+
+// Filter selects records
+type Filter struct {
+	// MinLevel is the least severe level selected, if HasLevel is set
+	MinLevel zapcore.Level
+	HasLevel bool
+	// Since and Until bound the entry time unless zero
+	Since, Until time.Time
+	// Msg matches the message unless nil
+	Msg *regexp.Regexp
+	// Exprs must all match
+	Exprs []Expr
+}
+
+// Match reports whether r passes every condition of f
+func (f *Filter) Match(r *Record) bool {
+	if f.HasLevel && filter.LevelRank(r.Entry.Level) < filter.LevelRank(f.MinLevel) {
+		return false
+	}
+	if !f.Since.IsZero() && r.Entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && r.Entry.Time.After(f.Until) {
+		return false
+	}
+	if f.Msg != nil && !f.Msg.MatchString(r.Entry.Message) {
+		return false
+	}
+	for _, e := range f.Exprs {
+		if !e.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Expr is a field expression such as "user_id=42" or "duration>100ms"
+type Expr struct {
+	key   string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+// exprOps are the operators of field expressions, longest first
+var exprOps = []string{"!=", ">=", "<=", "=", ">", "<", "~"}
+
+// ParseExpr parses a field expression: a key, one of the operators =, !=,
+// >, >=, <, <= or ~ (regular expression match) and a value
+func ParseExpr(s string) (Expr, error) {
+	i := strings.IndexAny(s, "!=<>~")
+	if i <= 0 {
+		return Expr{}, fmt.Errorf("invalid expression %q: want key, operator and value, e.g. user_id=42", s)
+	}
+	e := Expr{key: s[:i]}
+	for _, op := range exprOps {
+		if strings.HasPrefix(s[i:], op) {
+			e.op = op
+			break
+		}
+	}
+	if e.op == "" {
+		return Expr{}, fmt.Errorf("invalid operator in expression %q", s)
+	}
+	e.value = s[i+len(e.op):]
+	if e.op == "~" {
+		re, err := regexp.Compile(e.value)
+		if err != nil {
+			return Expr{}, fmt.Errorf("invalid expression %q: %w", s, err)
+		}
+		e.re = re
+	}
+	return e, nil
+}
+
+// Match reports whether the field of r named by e satisfies it. Values are
+// compared as durations if the expression's value is a duration such as
+// "100ms", as numbers if both are numbers, and as strings otherwise. A
+// missing field only matches !=.
+func (e Expr) Match(r *Record) bool {
+	v, ok := r.Lookup(e.key)
+	if !ok {
+		return e.op == "!="
+	}
+	if e.op == "~" {
+		return e.re.MatchString(StringValue(v))
+	}
+
+	var c int
+	if want, err := time.ParseDuration(e.value); err == nil && !isNumber(e.value) {
+		got, ok := DurationValue(v)
+		if !ok {
+			return e.op == "!="
+		}
+		c = cmp.Compare(got, want)
+	} else if want, err := strconv.ParseFloat(e.value, 64); err == nil {
+		got, err := strconv.ParseFloat(StringValue(v), 64)
+		if err != nil {
+			return e.op == "!="
+		}
+		c = cmp.Compare(got, want)
+	} else {
+		c = strings.Compare(StringValue(v), e.value)
+	}
+
+	switch e.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+// isNumber reports whether s is a plain number
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,69 @@
+package logfile
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kingrain94/logger"
+	"github.com/kingrain94/logger/internal/filter"
+)
+
+// FilterFlags holds the filter flags shared by the commands
+type FilterFlags struct {
+	level, since, until, msg string
+	where                    stringsFlag
+}
+
+// stringsFlag collects the values of a repeated flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Register defines -level, -since, -until, -msg and -where in flags
+func (ff *FilterFlags) Register(flags *flag.FlagSet) {
+	flags.StringVar(&ff.level, "level", "", "select entries at or above `level`, e.g. warn")
+	flags.StringVar(&ff.since, "since", "", "select entries after `time`, RFC 3339 or a duration ago such as 15m")
+	flags.StringVar(&ff.until, "until", "", "select entries before `time`, RFC 3339 or a duration ago such as 15m")
+	flags.StringVar(&ff.msg, "msg", "", "select entries whose message matches `regexp`")
+	flags.Var(&ff.where, "where", "select entries whose field matches `expr`, e.g. user_id=42 or duration>100ms (repeatable)")
+}
+
+// Filter builds the filter described by the parsed flags, with durations
+// in -since and -until relative to now
+func (ff *FilterFlags) Filter(now time.Time) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if ff.level != "" {
+		if f.MinLevel, err = logger.ParseLevel(ff.level); err != nil {
+			return nil, err
+		}
+		f.HasLevel = true
+	}
+	if f.Since, err = filter.ParseTime(ff.since, now); err != nil {
+		return nil, fmt.Errorf("invalid -since: %w", err)
+	}
+	if f.Until, err = filter.ParseTime(ff.until, now); err != nil {
+		return nil, fmt.Errorf("invalid -until: %w", err)
+	}
+	if ff.msg != "" {
+		if f.Msg, err = regexp.Compile(ff.msg); err != nil {
+			return nil, fmt.Errorf("invalid -msg: %w", err)
+		}
+	}
+	for _, s := range ff.where {
+		e, err := ParseExpr(s)
+		if err != nil {
+			return nil, err
+		}
+		f.Exprs = append(f.Exprs, e)
+	}
+	return f, nil
+}
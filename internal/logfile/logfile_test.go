@@ -0,0 +1,114 @@
+package logfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParse(t *testing.T) {
+	r, ok := Parse([]byte(`{"level":"warn","ts":1700000000.5,"caller":"api/handler.go:42","msg":"slow","user_id":7,"http":{"status":503}}` + "\n"))
+	if !ok {
+		t.Fatal("Parse() failed")
+	}
+	if r.Entry.Level != zapcore.WarnLevel || r.Entry.Message != "slow" ||
+		!r.Entry.Time.Equal(time.Unix(1700000000, 5e8)) || r.Entry.Caller.String() != "api/handler.go:42" {
+		t.Errorf("Entry = %+v", r.Entry)
+	}
+	if fields := r.Fields(); len(fields) != 2 || fields[0].Key != "user_id" || fields[1].Key != "http" {
+		t.Errorf("Fields() = %v", fields)
+	}
+	if v, ok := r.Lookup("http.status"); !ok || v != json.Number("503") {
+		t.Errorf("Lookup(http.status) = %v, %v", v, ok)
+	}
+	if _, ok := r.Lookup("http.method"); ok {
+		t.Error("Lookup(http.method) found a missing key")
+	}
+
+	for _, line := range []string{"", "panic: boom", "{not json", `{"a":1} trailing`, `["a"]`} {
+		if _, ok := Parse([]byte(line)); ok {
+			t.Errorf("Parse(%q) succeeded", line)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r, _ := Parse([]byte(`{"level":"warn","ts":"2023-11-14T22:14:20Z","msg":"slow request","user_id":7,"duration":0.25,"latency":"1.5s","http":{"status":503}}`))
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"user_id=7", true},
+		{"user_id=7.0", true},
+		{"user_id!=7", false},
+		{"user_id>5", true},
+		{"user_id<=6", false},
+		{"duration>100ms", true},
+		{"duration>=1s", false},
+		{"latency>1s", true},
+		{"http.status>=500", true},
+		{"msg~^slow", true},
+		{"missing=1", false},
+		{"missing!=1", true},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseExpr(%q): %v", tt.expr, err)
+		}
+		if got := e.Match(r); got != tt.want {
+			t.Errorf("%q matched = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	f := &Filter{MinLevel: zapcore.ErrorLevel, HasLevel: true}
+	if f.Match(r) {
+		t.Error("warn entry passed an error level filter")
+	}
+	f = &Filter{Since: time.Date(2023, 11, 14, 22, 14, 0, 0, time.UTC)}
+	if !f.Match(r) {
+		t.Error("entry after Since did not match")
+	}
+
+	for _, s := range []string{"user_id", "=42", "msg~("} {
+		if _, err := ParseExpr(s); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded", s)
+		}
+	}
+}
+
+func TestOpenGzip(t *testing.T) {
+	const content = "{\"msg\":\"one\"}\n{\"msg\":\"two\"}"
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	dir := t.TempDir()
+	plain, gz := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.1.gz")
+	os.WriteFile(plain, []byte(content), 0o644)
+	os.WriteFile(gz, compressed.Bytes(), 0o644)
+
+	for _, name := range []string{plain, gz, "-"} {
+		r, err := Open(name, bytes.NewReader(compressed.Bytes()))
+		if err != nil {
+			t.Fatalf("Open(%s): %v", name, err)
+		}
+		var lines []string
+		err = Lines(r, func(line []byte) error {
+			lines = append(lines, string(line))
+			return nil
+		})
+		r.Close()
+		if err != nil || strings.Join(lines, "") != content || len(lines) != 2 {
+			t.Errorf("Open(%s) lines = %q, %v", name, lines, err)
+		}
+	}
+}
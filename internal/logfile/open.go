@@ -0,0 +1,75 @@
+package logfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Open opens a log file, or stdin for "-". Gzip-compressed input, such as
+// a rotated file, is decompressed as it is read.
+func Open(name string, stdin io.Reader) (io.ReadCloser, error) {
+	var r io.Reader = stdin
+	var closer io.Closer = io.NopCloser(stdin)
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		r, closer = f, f
+	}
+
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return readCloser{br, closer}, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		closer.Close()
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	return readCloser{zr, multiCloser{zr, closer}}, nil
+}
+
+// readCloser combines a reader with the closer of its source
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// multiCloser closes a gzip reader and its file
+type multiCloser [2]io.Closer
+
+func (c multiCloser) Close() error {
+	err := c[0].Close()
+	if err2 := c[1].Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Lines calls fn with every line of r, including the newline if there is
+// one
+func Lines(r io.Reader, fn func(line []byte) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
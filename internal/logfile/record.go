@@ -0,0 +1,186 @@
+// Package logfile reads JSON log files written by the logger, for the
+// logview and logquery commands.
+package logfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Keys of the JSON encoding used by the Staging and Production configs
+const (
+	TimeKey   = "ts"
+	LevelKey  = "level"
+	NameKey   = "logger"
+	CallerKey = "caller"
+	MsgKey    = "msg"
+	StackKey  = "stacktrace"
+)
+
+// Record is a decoded JSON log line
+type Record struct {
+	Entry zapcore.Entry
+	// Keys holds the keys of the line in their original order
+	Keys []string
+	// Values holds the decoded values, with numbers as json.Number
+	Values map[string]interface{}
+}
+
+// Parse decodes a JSON log line. It returns false for lines that are not
+// JSON objects.
+func Parse(line []byte) (*Record, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+
+	r := &Record{Values: map[string]interface{}{}}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, false
+		}
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, false
+		}
+		if _, dup := r.Values[key]; !dup {
+			r.Keys = append(r.Keys, key)
+		}
+		r.Values[key] = value
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('}') {
+		return nil, false
+	}
+	if dec.InputOffset() != int64(len(line)) {
+		return nil, false
+	}
+
+	r.Entry = r.decodeEntry()
+	return r, true
+}
+
+// decodeEntry returns the entry described by the line's entry keys
+func (r *Record) decodeEntry() zapcore.Entry {
+	ent := zapcore.Entry{Level: zapcore.InfoLevel}
+	if t, ok := parseTime(r.Values[TimeKey]); ok {
+		ent.Time = t
+	}
+	if s, ok := r.Values[LevelKey].(string); ok {
+		if level, err := logger.ParseLevel(s); err == nil {
+			ent.Level = level
+		}
+	}
+	ent.LoggerName, _ = r.Values[NameKey].(string)
+	ent.Message, _ = r.Values[MsgKey].(string)
+	ent.Stack, _ = r.Values[StackKey].(string)
+	if s, ok := r.Values[CallerKey].(string); ok {
+		if i := strings.LastIndexByte(s, ':'); i > 0 {
+			line, _ := strconv.Atoi(s[i+1:])
+			ent.Caller = zapcore.EntryCaller{Defined: true, File: s[:i], Line: line}
+		}
+	}
+	return ent
+}
+
+// Fields returns the fields of the line that are not entry keys, in their
+// original order
+func (r *Record) Fields() []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(r.Keys))
+	for _, key := range r.Keys {
+		switch key {
+		case TimeKey, LevelKey, NameKey, CallerKey, MsgKey, StackKey:
+			continue
+		}
+		fields = append(fields, zap.Any(key, r.Values[key]))
+	}
+	return fields
+}
+
+// Lookup returns the value of a possibly dotted key, e.g. "http.status"
+// for a nested object
+func (r *Record) Lookup(key string) (interface{}, bool) {
+	if v, ok := r.Values[key]; ok {
+		return v, true
+	}
+	var v interface{} = map[string]interface{}(r.Values)
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// parseTime parses a time encoded as epoch seconds, as by zap's production
+// config, or as an RFC 3339 or ISO 8601 string
+func parseTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// DurationValue converts a duration encoded as a string such as "1.5s",
+// or as a number of seconds as by zap's production config
+func DurationValue(v interface{}) (time.Duration, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return time.Duration(f * float64(time.Second)), err == nil
+	case string:
+		d, err := time.ParseDuration(v)
+		return d, err == nil
+	}
+	return 0, false
+}
+
+// StringValue formats a decoded value, e.g. for comparison or grouping
+func StringValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// internalErrors counts every internal error reported by any logger built
+// by this package since the process started.
+var internalErrors atomic.Uint64
+
+// InternalErrors returns the number of internal errors the logger has
+// reported, such as failed writes to an output. A growing value means log
+// entries are being lost (disk full, closed pipe, ...).
+func InternalErrors() uint64 {
+	return internalErrors.Load()
+}
+
+// errorReporter wraps the logger's error output. zap writes one line to the
+// error output for every internal failure, so each Write is one error.
+type errorReporter struct {
+	zapcore.WriteSyncer
+	onError func(error)
+}
+
+func newErrorReporter(ws zapcore.WriteSyncer, onError func(error)) *errorReporter {
+	return &errorReporter{WriteSyncer: ws, onError: onError}
+}
+
+// Write records the internal error and forwards it to the error output
+func (r *errorReporter) Write(p []byte) (int, error) {
+	internalErrors.Add(1)
+	if r.onError != nil {
+		r.report(errors.New(strings.TrimSpace(string(p))))
+	}
+	return r.WriteSyncer.Write(p)
+}
+
+// report calls the user callback, making sure a panicking callback cannot
+// take down the goroutine that was trying to log.
+func (r *errorReporter) report(err error) {
+	defer func() { _ = recover() }()
+	r.onError(err)
+}
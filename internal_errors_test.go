@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// failingSink is an output whose writes always fail
+type failingSink struct{}
+
+func (failingSink) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+func (failingSink) Sync() error               { return nil }
+func (failingSink) Close() error              { return nil }
+
+var registerFailingSink sync.Once
+
+func TestInternalErrors(t *testing.T) {
+	registerFailingSink.Do(func() {
+		if err := zap.RegisterSink("failing", func(*url.URL) (zap.Sink, error) {
+			return failingSink{}, nil
+		}); err != nil {
+			t.Fatalf("RegisterSink() error = %v", err)
+		}
+	})
+	defer Initialize(DefaultConfig(Test))
+
+	errPath := filepath.Join(t.TempDir(), "errors.log")
+	var reported []error
+	config := Config{
+		Environment:      Test,
+		Level:            zapcore.InfoLevel,
+		OutputPaths:      []string{"failing://"},
+		Encoding:         "json",
+		ErrorOutputPaths: []string{errPath},
+		OnInternalError: func(err error) {
+			reported = append(reported, err)
+		},
+	}
+	if err := Initialize(config); err != nil {
+		t.Fatalf("Initialize() error = %v, want nil", err)
+	}
+
+	before := InternalErrors()
+	Info("lost message")
+	Info("another lost message")
+
+	if got := InternalErrors() - before; got != 2 {
+		t.Errorf("InternalErrors() increased by %d, want 2", got)
+	}
+	if len(reported) != 2 {
+		t.Fatalf("OnInternalError called %d times, want 2", len(reported))
+	}
+	if !strings.Contains(reported[0].Error(), "disk full") {
+		t.Errorf("reported error = %q, want it to mention the sink error", reported[0])
+	}
+
+	data, err := os.ReadFile(errPath)
+	if err != nil {
+		t.Fatalf("reading error output: %v", err)
+	}
+	if got := strings.Count(string(data), "write error"); got != 2 {
+		t.Errorf("error output has %d write errors, want 2:\n%s", got, data)
+	}
+}
+
+func TestInternalErrorCallbackPanic(t *testing.T) {
+	r := newErrorReporter(zapcore.AddSync(&strings.Builder{}), func(error) {
+		panic("callback failure")
+	})
+	if _, err := r.Write([]byte("write error\n")); err != nil {
+		t.Errorf("Write() error = %v, want nil", err)
+	}
+}
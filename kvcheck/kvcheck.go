@@ -0,0 +1,92 @@
+// Package kvcheck provides a go/analysis analyzer that checks the key-value
+// arguments passed to the logger's loosely typed functions (Debugw, Infow,
+// Warnw, Errorw, Fatalw and Panicw) and to the matching zap.SugaredLogger
+// methods.
+//
+// Arguments after the message must be key-value pairs with string keys,
+// optionally interleaved with strongly typed zap.Field values and errors,
+// which zap logs under the "error" key. The analyzer
+// reports a key without a value and keys that are not strings, which zap
+// would otherwise only report at runtime as an "ignored key" error.
+package kvcheck
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/kingrain94/logger/internal/analysisutil"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+)
+
+// Analyzer reports malformed key-value arguments to the logger's w functions
+var Analyzer = &analysis.Analyzer{
+	Name:     "kvcheck",
+	Doc:      "check key-value arguments passed to Debugw, Infow, Warnw, Errorw, Fatalw and Panicw",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// kvFuncs are the checked function and method names
+var kvFuncs = map[string]bool{
+	"Debugw":  true,
+	"Infow":   true,
+	"Warnw":   true,
+	"Errorw":  true,
+	"DPanicw": true,
+	"Panicw":  true,
+	"Fatalw":  true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	analysisutil.Calls(pass, kvFuncs, func(call *ast.CallExpr, _ string) {
+		if call.Ellipsis.IsValid() || len(call.Args) < 1 {
+			return
+		}
+		checkKeysAndValues(pass, call.Args[1:])
+	})
+
+	return nil, nil
+}
+
+// checkKeysAndValues mirrors the way zap's sugared logger consumes its
+// arguments: a zap.Field stands on its own, anything else is a key that must
+// be a string and must be followed by a value.
+func checkKeysAndValues(pass *analysis.Pass, args []ast.Expr) {
+	for i := 0; i < len(args); {
+		arg := args[i]
+		typ := pass.TypesInfo.TypeOf(arg)
+		if typ != nil && (isField(typ) || isError(typ)) {
+			i++
+			continue
+		}
+
+		if i == len(args)-1 {
+			pass.Reportf(arg.Pos(), "odd number of key-value arguments: key %s has no value", types.ExprString(arg))
+			return
+		}
+		if typ != nil && !isString(typ) {
+			pass.Reportf(arg.Pos(), "non-string key %s of type %s", types.ExprString(arg), typ)
+		}
+		i += 2
+	}
+}
+
+// isField reports whether t is zapcore.Field or its alias zap.Field
+func isField(t types.Type) bool {
+	return analysisutil.IsNamed(t, analysisutil.ZapcorePath, "Field") || analysisutil.IsNamed(t, analysisutil.ZapPath, "Field")
+}
+
+// errorType is the predeclared error interface
+var errorType = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+// isError reports whether t implements error
+func isError(t types.Type) bool {
+	return types.Implements(t, errorType)
+}
+
+// isString reports whether t is a string type, including untyped constants
+func isString(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
@@ -0,0 +1,12 @@
+package kvcheck_test
+
+import (
+	"testing"
+
+	"github.com/kingrain94/logger/kvcheck"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), kvcheck.Analyzer, "a")
+}
@@ -0,0 +1,31 @@
+package a
+
+import (
+	"github.com/kingrain94/logger"
+	"go.uber.org/zap"
+)
+
+type key string
+
+func calls(userID int, args []interface{}, err error) {
+	logger.Infow("ok", "user_id", userID, "ip", "127.0.0.1")
+	logger.Infow("no pairs")
+	logger.Debugw("typed field", zap.String("k", "v"), "user_id", userID)
+	logger.Warnw("named string key", key("user_id"), userID)
+	logger.Errorw("spread", args...)
+	logger.Errorw("failed", err, "user_id", 42)
+	logger.Errorw("failed", "user_id", 42, err)
+	logger.Infof("not checked %d", userID)
+
+	logger.Infow("odd", "user_id", userID, "ip")                      // want `odd number of key-value arguments: key "ip" has no value`
+	logger.Errorw("odd", "user_id")                                   // want `odd number of key-value arguments: key "user_id" has no value`
+	logger.Fatalw("bad key", userID, "user_id")                       // want `non-string key userID of type int`
+	logger.Panicw("bad key", 42, "answer")                            // want `non-string key 42 of type int`
+	logger.Errorw("error then odd", err, "user_id")                   // want `odd number of key-value arguments: key "user_id" has no value`
+	logger.Debugw("field then odd", zap.String("k", "v"), "dangling") // want `odd number of key-value arguments: key "dangling" has no value`
+
+	sugar := logger.GetSugar()
+	sugar.Infow("ok", "user_id", userID)
+	sugar.Infow("odd", "user_id") // want `odd number of key-value arguments: key "user_id" has no value`
+	sugar.Infof("not checked %d", userID)
+}
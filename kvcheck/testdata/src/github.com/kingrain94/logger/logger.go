@@ -0,0 +1,15 @@
+// Package logger is a minimal stand-in for github.com/kingrain94/logger used
+// by the tests.
+package logger
+
+import "go.uber.org/zap"
+
+func Debugw(msg string, keysAndValues ...interface{}) {}
+func Infow(msg string, keysAndValues ...interface{})  {}
+func Warnw(msg string, keysAndValues ...interface{})  {}
+func Errorw(msg string, keysAndValues ...interface{}) {}
+func Fatalw(msg string, keysAndValues ...interface{}) {}
+func Panicw(msg string, keysAndValues ...interface{}) {}
+func Infof(template string, args ...interface{})      {}
+
+func GetSugar() *zap.SugaredLogger { return &zap.SugaredLogger{} }
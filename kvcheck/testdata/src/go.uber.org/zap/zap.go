@@ -0,0 +1,13 @@
+// Package zap is a minimal stand-in for go.uber.org/zap used by the tests.
+package zap
+
+import "go.uber.org/zap/zapcore"
+
+type Field = zapcore.Field
+
+func String(key, val string) Field { return Field{Key: key} }
+
+type SugaredLogger struct{}
+
+func (s *SugaredLogger) Infow(msg string, keysAndValues ...interface{}) {}
+func (s *SugaredLogger) Infof(template string, args ...interface{})     {}
@@ -0,0 +1,7 @@
+// Package zapcore is a minimal stand-in for go.uber.org/zap/zapcore used by
+// the tests.
+package zapcore
+
+type Field struct {
+	Key string
+}
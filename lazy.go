@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Lazy returns a field whose value is computed by fn only when the entry
+// is written, i.e. after it passed the level and sampling checks. The value
+// is encoded like zap.Any. Lazy does not allocate, so a disabled call costs
+// nothing more than the closure fn.
+//
+// fn may be called once for every output that encodes the entry, e.g. for
+// the log file and the OTLP exporter, so it should not have side effects.
+// For a logger returned by With, fn is called when the field is added.
+// With Config.RecentBuffer set, fn is also called for entries below the
+// level, as the buffer records them.
+func Lazy(key string, fn func() interface{}) zap.Field {
+	return zap.Field{Key: key, Type: zapcore.ReflectType, Interface: lazyValue(fn)}
+}
+
+// LazyObject is like Lazy for a value implementing zapcore.ObjectMarshaler
+func LazyObject(key string, fn func() zapcore.ObjectMarshaler) zap.Field {
+	return zap.Field{Key: key, Type: zapcore.ObjectMarshalerType, Interface: lazyObject(fn)}
+}
+
+// lazyValue computes the value of a Lazy field. Being a func type, it is
+// stored in a zap.Field without allocating. The field is reflected, as that
+// is the only field type passing both the key and an arbitrary value to the
+// encoder: zap's JSON encoder calls MarshalJSON, and the encoders of this
+// package and the values of a zapcore.MapObjectEncoder call value.
+type lazyValue func() interface{}
+
+// value returns the value computed by fn as zap.Any adds it to a
+// zapcore.MapObjectEncoder
+func (fn lazyValue) value() interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	zap.Any("value", fn()).AddTo(enc)
+	return enc.Fields["value"]
+}
+
+// MarshalJSON implements json.Marshaler
+func (fn lazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fn.value())
+}
+
+// resolveLazy replaces the lazy values in fields encoded by a
+// zapcore.MapObjectEncoder with their values
+func resolveLazy(fields map[string]interface{}) {
+	for key, value := range fields {
+		if fn, ok := value.(lazyValue); ok {
+			fields[key] = fn.value()
+		}
+	}
+}
+
+// lazyObject marshals the object returned by the function. Being a func
+// type, it is stored in a zap.Field without allocating.
+type lazyObject func() zapcore.ObjectMarshaler
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (fn lazyObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if obj := fn(); obj != nil {
+		return obj.MarshalLogObject(enc)
+	}
+	return nil
+}
+
+// Enabled reports whether entries at level are written, so that the
+// arguments of a call can be skipped when they are not:
+//
+//	if logger.Enabled(zapcore.DebugLevel) {
+//		logger.Debug("state", zap.Any("diff", diff(old, new)))
+//	}
+//
+// With Config.RecentBuffer set every level is enabled, as the buffer
+// records them all.
+func Enabled(level zapcore.Level) bool {
+	l := GetLogger()
+	return l != nil && l.Core().Enabled(level)
+}
@@ -0,0 +1,209 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// diff is an object that is expensive to build
+type diff struct {
+	changed int
+}
+
+func (d diff) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("changed", d.changed)
+	return nil
+}
+
+func TestLazy(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	calls := 0
+	value := func() interface{} {
+		calls++
+		return []string{"a", "b"}
+	}
+	object := func() zapcore.ObjectMarshaler {
+		calls++
+		return diff{changed: 3}
+	}
+	Debug("skipped", Lazy("keys", value), LazyObject("diff", object))
+	if calls != 0 {
+		t.Errorf("lazy fields of a disabled entry evaluated %d times", calls)
+	}
+	Info("written", Lazy("keys", value), LazyObject("diff", object), LazyObject("none", func() zapcore.ObjectMarshaler { return nil }),
+		Lazy("object", func() interface{} { return diff{changed: 5} }))
+	if calls != 2 {
+		t.Errorf("lazy fields evaluated %d times, want 2", calls)
+	}
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	keys, _ := entries[0]["keys"].([]interface{})
+	if len(keys) != 2 || keys[0] != "a" {
+		t.Errorf("keys = %v", entries[0]["keys"])
+	}
+	if d, _ := entries[0]["diff"].(map[string]interface{}); d["changed"] != float64(3) {
+		t.Errorf("diff = %v", entries[0]["diff"])
+	}
+	// Values are encoded like zap.Any, using MarshalLogObject
+	if d, _ := entries[0]["object"].(map[string]interface{}); d["changed"] != float64(5) {
+		t.Errorf("object = %v", entries[0]["object"])
+	}
+	if none, ok := entries[0]["none"].(map[string]interface{}); !ok || len(none) != 0 {
+		t.Errorf("none = %v", entries[0]["none"])
+	}
+}
+
+func TestLazyEncodings(t *testing.T) {
+	lines := prettyLines(t, PrettyConfig{Color: ColorNever}, func() {
+		Info("pretty", Lazy("name", func() interface{} { return "alice" }), Lazy("attempt", func() interface{} { return 3 }))
+	})
+	if output := strings.Join(lines, "\n"); !strings.Contains(output, "\n    name: alice\n    attempt: 3") {
+		t.Errorf("pretty output:\n%s", output)
+	}
+
+	config := DefaultConfig(Staging)
+	config.Limits = &Limits{MaxStringLength: 4}
+	read := initializeToFile(t, config)
+	calls := 0
+	Info("limited", Lazy("body", func() interface{} {
+		calls++
+		return "0123456789"
+	}))
+	entries := decodeEntries(t, read())
+	if body, _ := entries[0]["body"].(string); !strings.HasPrefix(body, "0123…(truncated") {
+		t.Errorf("body = %q, want it truncated", body)
+	}
+	if calls != 1 {
+		t.Errorf("lazy field evaluated %d times, want 1", calls)
+	}
+}
+
+func TestLazySampling(t *testing.T) {
+	for _, level := range []zapcore.Level{zapcore.InfoLevel, zapcore.ErrorLevel} {
+		t.Run(level.String(), func(t *testing.T) {
+			// The production config samples identical messages after the
+			// first 100 per second. Error entries also go to the error
+			// groups and to hooks, which must only see written entries.
+			config := DefaultConfig(Staging)
+			config.ErrorSummary = true
+			read := initializeToFile(t, config)
+			remove := AddHook(zapcore.ErrorLevel, func(_ zapcore.Entry, fields []zapcore.Field) error {
+				enc := zapcore.NewMapObjectEncoder()
+				for _, f := range fields {
+					f.AddTo(enc)
+				}
+				_, err := json.Marshal(enc.Fields)
+				return err
+			})
+			defer remove()
+
+			calls := 0
+			for i := 0; i < 150; i++ {
+				GetLogger().Check(level, "repeated").Write(Lazy("n", func() interface{} {
+					calls++
+					return calls
+				}))
+			}
+			written := len(read())
+			if written == 150 {
+				t.Fatal("no entries sampled")
+			}
+			// Each written entry is encoded by the output and, at error
+			// level, by the hook. The first is also encoded as the sample
+			// of its error group.
+			want := written
+			if level == zapcore.ErrorLevel {
+				want = 2*written + 1
+			}
+			if calls != want {
+				t.Errorf("lazy field evaluated %d times for %d written entries out of 150, want %d", calls, written, want)
+			}
+		})
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Staging))
+	if Enabled(zapcore.DebugLevel) || Enabled(TraceLevel) {
+		t.Error("debug enabled at info level")
+	}
+	if !Enabled(zapcore.InfoLevel) || !Enabled(NoticeLevel) || !Enabled(CriticalLevel) {
+		t.Error("info and above disabled at info level")
+	}
+	SetLevel(TraceLevel)
+	if !Enabled(TraceLevel) {
+		t.Error("trace disabled after SetLevel(TraceLevel)")
+	}
+}
+
+// lazyDiff and lazyKeys are lazy field functions that do not capture
+// variables
+func lazyDiff() zapcore.ObjectMarshaler {
+	return diff{changed: 1}
+}
+
+func lazyKeys() interface{} {
+	return []string{"a", "b"}
+}
+
+func TestDisabledDoesNotAllocate(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Production))
+
+	tests := map[string]func(){
+		"Enabled": func() {
+			if Enabled(zapcore.DebugLevel) {
+				Debug("state", zap.Any("diff", diff{changed: 1}))
+			}
+		},
+		"fields":     func() { Debug("state", zap.Int("attempt", 1), zap.String("user", "alice")) },
+		"Lazy":       func() { Debug("state", Lazy("keys", lazyKeys)) },
+		"LazyObject": func() { Info("state", LazyObject("diff", lazyDiff)) },
+	}
+	for name, fn := range tests {
+		if allocs := testing.AllocsPerRun(100, fn); allocs != 0 {
+			t.Errorf("%s: %v allocations, want 0", name, allocs)
+		}
+	}
+}
+
+func BenchmarkEnabledDisabled(b *testing.B) {
+	Initialize(DefaultConfig(Production))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if Enabled(zapcore.DebugLevel) {
+			Debug("state", zap.Any("diff", diff{changed: i}))
+		}
+	}
+}
+
+func BenchmarkDebugDisabled(b *testing.B) {
+	Initialize(DefaultConfig(Production))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Debug("state", zap.Int("attempt", i))
+	}
+}
+
+func BenchmarkLazyDisabled(b *testing.B) {
+	Initialize(DefaultConfig(Production))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Debug("state", Lazy("diff", func() interface{} { return diff{changed: i} }))
+	}
+}
+
+func BenchmarkLazyObjectDisabled(b *testing.B) {
+	Initialize(DefaultConfig(Production))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Debug("state", LazyObject("diff", lazyDiff))
+	}
+}
@@ -0,0 +1,217 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kingrain94/logger/internal/filter"
+	"go.uber.org/zap/zapcore"
+)
+
+// Custom log levels in addition to zap's DEBUG..FATAL.
+//
+// zap's levels are consecutive integers, so there is no room for NOTICE
+// between INFO and WARN or for CRITICAL between ERROR and DPANIC. Those two
+// levels therefore live above zap's range and are ordered by this package's
+// level filtering instead of by their integer value. Use ParseLevel, SetLevel
+// and the package logging functions rather than comparing levels directly.
+const (
+	// TraceLevel logs are finer grained than Debug, e.g. wire dumps.
+	TraceLevel = filter.TraceLevel
+	// NoticeLevel logs are normal but significant events, between Info and Warn.
+	NoticeLevel = filter.NoticeLevel
+	// CriticalLevel logs are severe errors, between Error and DPanic.
+	CriticalLevel = filter.CriticalLevel
+)
+
+// allLevels is lower than any level, used for cores whose filtering is
+// delegated to a levelCore
+const allLevels = zapcore.Level(math.MinInt8)
+
+// levelAtLeast reports whether l is at least as severe as min
+func levelAtLeast(l, min zapcore.Level) bool {
+	return filter.LevelRank(l) >= filter.LevelRank(min)
+}
+
+// ParseLevel parses a level name such as "trace", "info", "notice" or
+// "CRITICAL". In addition to zap's level names it accepts the custom levels
+// and "warning" as an alias for "warn".
+func ParseLevel(text string) (zapcore.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "trace":
+		return TraceLevel, nil
+	case "notice":
+		return NoticeLevel, nil
+	case "warning":
+		return zapcore.WarnLevel, nil
+	case "critical":
+		return CriticalLevel, nil
+	}
+	level, err := zapcore.ParseLevel(text)
+	if err != nil {
+		return level, fmt.Errorf("failed to parse level: %w", err)
+	}
+	return level, nil
+}
+
+// LevelName returns the lowercase name of a level, including custom levels
+func LevelName(l zapcore.Level) string {
+	switch l {
+	case TraceLevel:
+		return "trace"
+	case NoticeLevel:
+		return "notice"
+	case CriticalLevel:
+		return "critical"
+	default:
+		return l.String()
+	}
+}
+
+// lowercaseLevelEncoder renders levels as "info", "notice", ...
+func lowercaseLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(LevelName(l))
+}
+
+// capitalLevelEncoder renders levels as "INFO", "NOTICE", ...
+func capitalLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case TraceLevel:
+		enc.AppendString("TRACE")
+	case NoticeLevel:
+		enc.AppendString("NOTICE")
+	case CriticalLevel:
+		enc.AppendString("CRITICAL")
+	default:
+		enc.AppendString(l.CapitalString())
+	}
+}
+
+// levelEnabler is a dynamic minimum level that understands custom levels
+type levelEnabler struct {
+	level atomic.Int32
+}
+
+// Enabled implements zapcore.LevelEnabler
+func (e *levelEnabler) Enabled(l zapcore.Level) bool {
+	return levelAtLeast(l, e.Level())
+}
+
+// Level returns the current minimum level
+func (e *levelEnabler) Level() zapcore.Level {
+	return zapcore.Level(e.level.Load())
+}
+
+// SetLevel changes the minimum level
+func (e *levelEnabler) SetLevel(l zapcore.Level) {
+	e.level.Store(int32(l))
+}
+
+// minLevel is a fixed minimum level that understands custom levels
+type minLevel zapcore.Level
+
+// Enabled implements zapcore.LevelEnabler
+func (m minLevel) Enabled(l zapcore.Level) bool {
+	return levelAtLeast(l, zapcore.Level(m))
+}
+
+// levelCore filters entries with a levelEnabler. The wrapped core must
+// accept every level.
+type levelCore struct {
+	zapcore.Core
+	enabler *levelEnabler
+}
+
+func newLevelCore(core zapcore.Core, enabler *levelEnabler) zapcore.Core {
+	return &levelCore{Core: core, enabler: enabler}
+}
+
+// Enabled implements zapcore.Core
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.enabler.Enabled(l)
+}
+
+// Level reports the minimum enabled level for zapcore.LevelOf
+func (c *levelCore) Level() zapcore.Level {
+	return c.enabler.Level()
+}
+
+// With implements zapcore.Core
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enabler: c.enabler}
+}
+
+// Check implements zapcore.Core
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// samplingLevel returns the zap level an entry is sampled as. zap's sampler
+// only counts DebugLevel to FatalLevel and lets other levels through, so
+// Trace is sampled as Debug, Notice as Info and Critical as Error, sharing
+// their counters.
+func samplingLevel(l zapcore.Level) zapcore.Level {
+	switch l {
+	case TraceLevel:
+		return zapcore.DebugLevel
+	case NoticeLevel:
+		return zapcore.InfoLevel
+	case CriticalLevel:
+		return zapcore.ErrorLevel
+	default:
+		return l
+	}
+}
+
+// samplerCore samples entries with a zap sampler that only decides, so
+// that entries at custom levels reach the wrapped core with their level.
+type samplerCore struct {
+	zapcore.Core
+	sampler zapcore.Core
+}
+
+func newSamplerCore(core zapcore.Core, tick time.Duration, first, thereafter int, opts ...zapcore.SamplerOption) zapcore.Core {
+	return &samplerCore{
+		Core:    core,
+		sampler: zapcore.NewSamplerWithOptions(sampledCore{}, tick, first, thereafter, opts...),
+	}
+}
+
+// With implements zapcore.Core. The counters are shared with c.
+func (c *samplerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplerCore{Core: c.Core.With(fields), sampler: c.sampler}
+}
+
+// Check implements zapcore.Core
+func (c *samplerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	sampled := ent
+	sampled.Level = samplingLevel(ent.Level)
+	if c.sampler.Check(sampled, nil) != sampledEntry {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// sampledEntry is returned by sampledCore for entries the sampler keeps
+var sampledEntry = new(zapcore.CheckedEntry)
+
+// sampledCore is the core under the sampler of a samplerCore
+type sampledCore struct{}
+
+func (sampledCore) Enabled(zapcore.Level) bool                 { return true }
+func (c sampledCore) With([]zapcore.Field) zapcore.Core        { return c }
+func (sampledCore) Write(zapcore.Entry, []zapcore.Field) error { return nil }
+func (sampledCore) Sync() error                                { return nil }
+
+func (sampledCore) Check(zapcore.Entry, *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return sampledEntry
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected zapcore.Level
+		wantErr  bool
+	}{
+		{"trace", TraceLevel, false},
+		{"DEBUG", zapcore.DebugLevel, false},
+		{"info", zapcore.InfoLevel, false},
+		{"Notice", NoticeLevel, false},
+		{"warn", zapcore.WarnLevel, false},
+		{"warning", zapcore.WarnLevel, false},
+		{"error", zapcore.ErrorLevel, false},
+		{"CRITICAL", CriticalLevel, false},
+		{"fatal", zapcore.FatalLevel, false},
+		{"verbose", zapcore.InfoLevel, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseLevel(tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseLevel(%q) error = nil, want error", tt.text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLevel(%q) error = %v", tt.text, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseLevel(%q) = %v, want %v", tt.text, LevelName(got), LevelName(tt.expected))
+			}
+		})
+	}
+}
+
+func TestLevelOrdering(t *testing.T) {
+	ordered := []zapcore.Level{
+		TraceLevel,
+		zapcore.DebugLevel,
+		zapcore.InfoLevel,
+		NoticeLevel,
+		zapcore.WarnLevel,
+		zapcore.ErrorLevel,
+		CriticalLevel,
+		zapcore.DPanicLevel,
+		zapcore.PanicLevel,
+		zapcore.FatalLevel,
+	}
+
+	for i := 1; i < len(ordered); i++ {
+		lower, higher := ordered[i-1], ordered[i]
+		if !levelAtLeast(higher, lower) || levelAtLeast(lower, higher) {
+			t.Errorf("%s should be more severe than %s", LevelName(higher), LevelName(lower))
+		}
+	}
+}
+
+func TestCustomLevelsJSON(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Level = TraceLevel
+	read := initializeToFile(t, config)
+
+	Trace("trace message")
+	Tracef("trace %s", "formatted")
+	Notice("notice message")
+	Noticef("notice %s", "formatted")
+	Critical("critical message")
+	Criticalf("critical %s", "formatted")
+
+	entries := decodeEntries(t, read())
+	expected := []string{"trace", "trace", "notice", "notice", "critical", "critical"}
+	if len(entries) != len(expected) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(expected))
+	}
+	for i, entry := range entries {
+		if entry["level"] != expected[i] {
+			t.Errorf("entry %d level = %v, want %v", i, entry["level"], expected[i])
+		}
+	}
+	if entries[1]["msg"] != "trace formatted" {
+		t.Errorf("Tracef message = %v, want %q", entries[1]["msg"], "trace formatted")
+	}
+}
+
+func TestCustomLevelsConsole(t *testing.T) {
+	config := DefaultConfig(Development)
+	config.Level = TraceLevel
+	read := initializeToFile(t, config)
+
+	Trace("trace message")
+	Notice("notice message")
+	Critical("critical message")
+
+	output := strings.Join(read(), "\n")
+	for _, name := range []string{"TRACE", "NOTICE", "CRITICAL"} {
+		if !strings.Contains(output, "\t"+name+"\t") {
+			t.Errorf("console output is missing level %s:\n%s", name, output)
+		}
+	}
+}
+
+func TestSetLevelCustomLevels(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Level = zapcore.ErrorLevel
+	read := initializeToFile(t, config)
+
+	Critical("kept at error")
+	Notice("dropped at error")
+
+	SetLevel(NoticeLevel)
+	if GetLevel() != NoticeLevel {
+		t.Errorf("GetLevel() = %v, want notice", LevelName(GetLevel()))
+	}
+	Info("dropped at notice")
+	Notice("kept at notice")
+	Warn("kept at notice")
+
+	SetLevel(TraceLevel)
+	Trace("kept at trace")
+
+	var got []string
+	for _, entry := range decodeEntries(t, read()) {
+		got = append(got, entry["msg"].(string))
+	}
+	want := []string{"kept at error", "kept at notice", "kept at notice", "kept at trace"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("logged messages = %v, want %v", got, want)
+	}
+}
+
+func TestCustomLevelsSampled(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Level = TraceLevel
+	read := initializeToFile(t, config)
+
+	// The production sampler keeps the first 100 identical entries per
+	// second and every 100th after that
+	for i := 0; i < 250; i++ {
+		Trace("repeated trace")
+		Notice("repeated notice")
+		Critical("repeated critical")
+	}
+
+	counts := map[string]int{}
+	for _, entry := range decodeEntries(t, read()) {
+		counts[entry["level"].(string)]++
+	}
+	for _, level := range []string{"trace", "notice", "critical"} {
+		if counts[level] != 101 {
+			t.Errorf("%s entries = %d, want 101", level, counts[level])
+		}
+	}
+}
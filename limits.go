@@ -0,0 +1,596 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TruncatedFieldsKey is the key of the field recording how many fields
+// were dropped from an entry with more than Limits.MaxFields
+const TruncatedFieldsKey = "truncated_fields"
+
+// Limits bounds the size of entries written to the outputs, exporters,
+// hooks and the recent buffer, so that a single huge entry cannot stall log
+// shipping. Truncated values end with a marker such as "…(truncated 39MB)".
+// A zero limit means no limit.
+// Truncated entries are counted by logger_truncated_entries_total, see
+// WriteMetrics.
+type Limits struct {
+	// MaxMessageBytes caps the message
+	MaxMessageBytes int
+	// MaxFields caps the number of fields passed to a log call; the rest
+	// are replaced by a TruncatedFieldsKey field with their count
+	MaxFields int
+	// MaxStringLength caps string, []byte, error and fmt.Stringer values,
+	// including those nested in objects and arrays, and the JSON encoding
+	// of values logged by reflection such as zap.Any of a struct
+	MaxStringLength int
+	// MaxArrayElements caps arrays; the rest are replaced by a marker
+	// element
+	MaxArrayElements int
+	// MaxDepth caps the nesting of objects and arrays; deeper values are
+	// replaced by a marker
+	MaxDepth int
+}
+
+// truncate cuts s to at most max bytes on a rune boundary and appends a
+// marker with the size removed. A max of zero or less means no limit.
+func truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + truncationMarker(len(s)-cut)
+}
+
+// truncationMarker describes n removed bytes
+func truncationMarker(n int) string {
+	return "…(truncated " + formatSize(n) + ")"
+}
+
+// formatSize formats a byte count with a binary unit, rounding down
+func formatSize(n int) string {
+	for _, unit := range []struct {
+		suffix string
+		size   int
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if n >= unit.size {
+			return strconv.Itoa(n/unit.size) + unit.suffix
+		}
+	}
+	return strconv.Itoa(n) + "B"
+}
+
+// limitCore applies Limits to the entries written to the wrapped cores. It
+// wraps every other core, so that outputs, exporters, hooks and the recent
+// buffer all see the limited entry.
+type limitCore struct {
+	zapcore.Core
+	limits      Limits
+	errorOutput zapcore.WriteSyncer
+	// contextTruncated is set if fields added with With were truncated, so
+	// that every entry carrying them counts as truncated
+	contextTruncated bool
+}
+
+func newLimitCore(core zapcore.Core, limits Limits, errorOutput zapcore.WriteSyncer) zapcore.Core {
+	return &limitCore{Core: core, limits: limits, errorOutput: errorOutput}
+}
+
+// With implements zapcore.Core
+func (c *limitCore) With(fields []zapcore.Field) zapcore.Core {
+	l := &limiter{limits: c.limits}
+	clone := *c
+	clone.Core = c.Core.With(l.fields(fields, 0))
+	clone.contextTruncated = c.contextTruncated || l.truncated
+	return &clone
+}
+
+// Check implements zapcore.Core. The wrapped cores that accept the entry,
+// e.g. after sampling, are collected in a checked entry of their own, which
+// is written with the limited entry.
+func (c *limitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	inner := c.Core.Check(ent, nil)
+	if inner == nil {
+		return ce
+	}
+	return ce.AddCore(ent, limitedEntry{c, inner})
+}
+
+// Write implements zapcore.Core for entries written directly, such as those
+// flushed by a Scope
+func (c *limitCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	l, ent, fields := c.limit(ent, fields)
+	// Nested values are limited while they are encoded
+	err := c.Core.Write(ent, fields)
+	l.count()
+	return err
+}
+
+// limit returns the limited entry and fields, and the limiter that records
+// whether they were truncated
+func (c *limitCore) limit(ent zapcore.Entry, fields []zapcore.Field) (*limiter, zapcore.Entry, []zapcore.Field) {
+	l := &limiter{limits: c.limits, truncated: c.contextTruncated}
+	if msg := truncate(ent.Message, c.limits.MaxMessageBytes); msg != ent.Message {
+		ent.Message = msg
+		l.truncated = true
+	}
+	if max := c.limits.MaxFields; max > 0 && len(fields) > max {
+		dropped := len(fields) - max
+		fields = append(fields[:max:max], zap.Int(TruncatedFieldsKey, dropped))
+		l.truncated = true
+	}
+	return l, ent, l.fields(fields, 0)
+}
+
+// limitedEntry is added to checked entries by limitCore to write the
+// limited entry to the wrapped cores that accepted it
+type limitedEntry struct {
+	*limitCore
+	inner *zapcore.CheckedEntry
+}
+
+// Write implements zapcore.Core. Write errors of the wrapped cores are
+// reported to the error output by the inner checked entry.
+func (e limitedEntry) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	l, ent, fields := e.limit(ent, fields)
+	// ent has the caller and stack trace added after Check
+	e.inner.Entry = ent
+	e.inner.ErrorOutput = e.errorOutput
+	e.inner.Write(fields...)
+	l.count()
+	return nil
+}
+
+// limiter applies Limits to the fields of one entry and records whether
+// anything was truncated
+type limiter struct {
+	limits    Limits
+	truncated bool
+}
+
+// count counts the entry as truncated if needed
+func (l *limiter) count() {
+	if l.truncated {
+		metrics.truncated.Add(1)
+	}
+}
+
+// string truncates s to MaxStringLength
+func (l *limiter) string(s string) string {
+	t := truncate(s, l.limits.MaxStringLength)
+	if len(t) != len(s) {
+		l.truncated = true
+	}
+	return t
+}
+
+// fields returns fields with their values limited, copying fields only if
+// one of them changes
+func (l *limiter) fields(fields []zapcore.Field, depth int) []zapcore.Field {
+	var limited []zapcore.Field
+	for i, f := range fields {
+		lf, changed := l.field(f, depth)
+		if !changed {
+			continue
+		}
+		if limited == nil {
+			limited = append([]zapcore.Field(nil), fields...)
+		}
+		limited[i] = lf
+	}
+	if limited == nil {
+		return fields
+	}
+	return limited
+}
+
+// field returns f with its value limited
+func (l *limiter) field(f zapcore.Field, depth int) (zapcore.Field, bool) {
+	switch f.Type {
+	case zapcore.StringType:
+		if s := l.string(f.String); len(s) != len(f.String) {
+			return zap.String(f.Key, s), true
+		}
+	case zapcore.ByteStringType, zapcore.BinaryType:
+		b := f.Interface.([]byte)
+		if max := l.limits.MaxStringLength; max > 0 && len(b) > max {
+			return zap.String(f.Key, l.string(string(b))), true
+		}
+	case zapcore.StringerType:
+		if l.limits.MaxStringLength > 0 {
+			f.Interface = limitedStringer{f.Interface.(fmt.Stringer), l}
+			return f, true
+		}
+	case zapcore.ErrorType:
+		if l.limits.MaxStringLength > 0 {
+			f.Interface = limitError(f.Interface.(error), l)
+			return f, true
+		}
+	case zapcore.ReflectType:
+		if fn, ok := f.Interface.(lazyValue); ok {
+			// Computed once here rather than by each output, and limited
+			// like the value itself
+			lf, _ := l.field(zap.Any(f.Key, fn()), depth)
+			return lf, true
+		}
+		if l.limits.MaxStringLength > 0 {
+			// Reflected values are encoded as JSON by zap. They are checked
+			// by encoding them once more, and logged as truncated JSON text
+			// if too long.
+			if data, err := json.Marshal(f.Interface); err == nil && len(data) > l.limits.MaxStringLength {
+				return zap.String(f.Key, l.string(string(data))), true
+			}
+		}
+	case zapcore.ObjectMarshalerType:
+		if l.nests() {
+			f.Interface = limitedObject{f.Interface.(zapcore.ObjectMarshaler), l, depth + 1}
+			return f, true
+		}
+	case zapcore.InlineMarshalerType:
+		// Inlined fields are added to the enclosing object
+		if l.nests() {
+			f.Interface = limitedObject{f.Interface.(zapcore.ObjectMarshaler), l, depth}
+			return f, true
+		}
+	case zapcore.ArrayMarshalerType:
+		if l.nests() {
+			f.Interface = limitedArray{f.Interface.(zapcore.ArrayMarshaler), l, depth + 1}
+			return f, true
+		}
+	}
+	return f, false
+}
+
+// nests reports whether nested values need to be limited
+func (l *limiter) nests() bool {
+	return l.limits.MaxStringLength > 0 || l.limits.MaxArrayElements > 0 || l.limits.MaxDepth > 0
+}
+
+// tooDeep reports whether a value at depth exceeds MaxDepth
+func (l *limiter) tooDeep(depth int) bool {
+	if l.limits.MaxDepth > 0 && depth > l.limits.MaxDepth {
+		l.truncated = true
+		return true
+	}
+	return false
+}
+
+// depthMarker replaces values nested deeper than MaxDepth
+const depthMarker = "…(truncated depth)"
+
+// limitedStringer truncates the result of a fmt.Stringer
+type limitedStringer struct {
+	fmt.Stringer
+	l *limiter
+}
+
+func (s limitedStringer) String() string {
+	return s.l.string(s.Stringer.String())
+}
+
+// limitError wraps err so that its message and what zap adds for it, the
+// verbose message of a fmt.Formatter or the causes of an error with an
+// Errors method, are truncated
+func limitError(err error, l *limiter) error {
+	e := limitedError{err, l}
+	switch err.(type) {
+	case interface{ Errors() []error }:
+		return limitedErrorGroup{e}
+	case fmt.Formatter:
+		return limitedFormatter{e}
+	}
+	return e
+}
+
+// limitedError truncates the message of an error
+type limitedError struct {
+	error
+	l *limiter
+}
+
+func (e limitedError) Error() string {
+	return e.l.string(e.error.Error())
+}
+
+// Unwrap returns the wrapped error
+func (e limitedError) Unwrap() error {
+	return e.error
+}
+
+// limitedFormatter truncates the formatted message of an error
+type limitedFormatter struct {
+	limitedError
+}
+
+// Format implements fmt.Formatter
+func (e limitedFormatter) Format(s fmt.State, verb rune) {
+	fmt.Fprint(s, e.l.string(fmt.Sprintf(fmt.FormatString(s, verb), e.error)))
+}
+
+// limitedErrorGroup truncates the causes of an error
+type limitedErrorGroup struct {
+	limitedError
+}
+
+// Errors returns the limited causes
+func (e limitedErrorGroup) Errors() []error {
+	causes := e.error.(interface{ Errors() []error }).Errors()
+	limited := make([]error, len(causes))
+	for i, cause := range causes {
+		if cause != nil {
+			limited[i] = limitError(cause, e.l)
+		}
+	}
+	return limited
+}
+
+// limitedObject limits the values of an object while it is encoded
+type limitedObject struct {
+	obj   zapcore.ObjectMarshaler
+	l     *limiter
+	depth int
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (o limitedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.obj.MarshalLogObject(limitedObjectEncoder{enc, o.l, o.depth})
+}
+
+// limitedArray limits the elements of an array while it is encoded
+type limitedArray struct {
+	arr   zapcore.ArrayMarshaler
+	l     *limiter
+	depth int
+}
+
+// MarshalLogArray implements zapcore.ArrayMarshaler
+func (a limitedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	limited := &limitedArrayEncoder{ArrayEncoder: enc, l: a.l, depth: a.depth}
+	err := a.arr.MarshalLogArray(limited)
+	if limited.dropped > 0 {
+		enc.AppendString("…(truncated " + strconv.Itoa(limited.dropped) + " elements)")
+	}
+	return err
+}
+
+// limitedObjectEncoder limits the values added to an object
+type limitedObjectEncoder struct {
+	zapcore.ObjectEncoder
+	l     *limiter
+	depth int
+}
+
+func (e limitedObjectEncoder) AddString(key, value string) {
+	e.ObjectEncoder.AddString(key, e.l.string(value))
+}
+
+func (e limitedObjectEncoder) AddByteString(key string, value []byte) {
+	if max := e.l.limits.MaxStringLength; max > 0 && len(value) > max {
+		e.ObjectEncoder.AddString(key, e.l.string(string(value)))
+		return
+	}
+	e.ObjectEncoder.AddByteString(key, value)
+}
+
+func (e limitedObjectEncoder) AddBinary(key string, value []byte) {
+	if max := e.l.limits.MaxStringLength; max > 0 && len(value) > max {
+		e.ObjectEncoder.AddString(key, e.l.string(string(value)))
+		return
+	}
+	e.ObjectEncoder.AddBinary(key, value)
+}
+
+func (e limitedObjectEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	if e.l.tooDeep(e.depth + 1) {
+		e.ObjectEncoder.AddString(key, depthMarker)
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, limitedObject{obj, e.l, e.depth + 1})
+}
+
+func (e limitedObjectEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	if e.l.tooDeep(e.depth + 1) {
+		e.ObjectEncoder.AddString(key, depthMarker)
+		return nil
+	}
+	return e.ObjectEncoder.AddArray(key, limitedArray{arr, e.l, e.depth + 1})
+}
+
+func (e limitedObjectEncoder) AddReflected(key string, value interface{}) error {
+	if f, changed := e.l.field(zap.Reflect(key, value), e.depth); changed {
+		f.AddTo(e.ObjectEncoder)
+		return nil
+	}
+	return e.ObjectEncoder.AddReflected(key, value)
+}
+
+// limitedArrayEncoder limits the elements appended to an array, counting
+// those beyond MaxArrayElements instead of appending them
+type limitedArrayEncoder struct {
+	zapcore.ArrayEncoder
+	l       *limiter
+	depth   int
+	n       int
+	dropped int
+}
+
+// keep reports whether another element can be appended
+func (e *limitedArrayEncoder) keep() bool {
+	if max := e.l.limits.MaxArrayElements; max > 0 && e.n >= max {
+		e.dropped++
+		e.l.truncated = true
+		return false
+	}
+	e.n++
+	return true
+}
+
+func (e *limitedArrayEncoder) AppendBool(v bool) {
+	if e.keep() {
+		e.ArrayEncoder.AppendBool(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendByteString(v []byte) {
+	if !e.keep() {
+		return
+	}
+	if max := e.l.limits.MaxStringLength; max > 0 && len(v) > max {
+		e.ArrayEncoder.AppendString(e.l.string(string(v)))
+		return
+	}
+	e.ArrayEncoder.AppendByteString(v)
+}
+
+func (e *limitedArrayEncoder) AppendComplex128(v complex128) {
+	if e.keep() {
+		e.ArrayEncoder.AppendComplex128(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendComplex64(v complex64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendComplex64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendFloat64(v float64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendFloat64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendFloat32(v float32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendFloat32(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt(v int) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt64(v int64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt32(v int32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt32(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt16(v int16) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt16(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt8(v int8) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt8(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendString(v string) {
+	if e.keep() {
+		e.ArrayEncoder.AppendString(e.l.string(v))
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint(v uint) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint64(v uint64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint32(v uint32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint32(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint16(v uint16) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint16(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint8(v uint8) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint8(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUintptr(v uintptr) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUintptr(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendDuration(v time.Duration) {
+	if e.keep() {
+		e.ArrayEncoder.AppendDuration(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendTime(v time.Time) {
+	if e.keep() {
+		e.ArrayEncoder.AppendTime(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	if !e.keep() {
+		return nil
+	}
+	if e.l.tooDeep(e.depth + 1) {
+		e.ArrayEncoder.AppendString(depthMarker)
+		return nil
+	}
+	return e.ArrayEncoder.AppendArray(limitedArray{arr, e.l, e.depth + 1})
+}
+
+func (e *limitedArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	if !e.keep() {
+		return nil
+	}
+	if e.l.tooDeep(e.depth + 1) {
+		e.ArrayEncoder.AppendString(depthMarker)
+		return nil
+	}
+	return e.ArrayEncoder.AppendObject(limitedObject{obj, e.l, e.depth + 1})
+}
+
+func (e *limitedArrayEncoder) AppendReflected(v interface{}) error {
+	if !e.keep() {
+		return nil
+	}
+	if max := e.l.limits.MaxStringLength; max > 0 {
+		if data, err := json.Marshal(v); err == nil && len(data) > max {
+			e.ArrayEncoder.AppendString(e.l.string(string(data)))
+			return nil
+		}
+	}
+	return e.ArrayEncoder.AppendReflected(v)
+}
@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// nestedObject marshals an object nested depth levels deep
+type nestedObject int
+
+func (o nestedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("level", int(o))
+	if o > 1 {
+		return enc.AddObject("child", o-1)
+	}
+	return nil
+}
+
+// payload is logged by reflection
+type payload struct {
+	Body string `json:"body"`
+}
+
+func TestLimits(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Limits = &Limits{
+		MaxMessageBytes:  8,
+		MaxStringLength:  10,
+		MaxArrayElements: 3,
+		MaxDepth:         2,
+	}
+	read := initializeToFile(t, config)
+
+	body := strings.Repeat("x", 3<<20)
+	Warn("request failed with a long message",
+		zap.String("body", body),
+		zap.ByteString("raw", []byte("0123456789abc")),
+		zap.Ints("ids", []int{1, 2, 3, 4, 5}),
+		zap.Strings("names", []string{"short", "a much longer name"}),
+		zap.Object("tree", nestedObject(4)),
+		zap.Any("payload", payload{Body: "too long to keep"}),
+		zap.Error(errors.New("connection reset by peer")),
+		zap.Stringer("level", zapcore.WarnLevel),
+		zap.Int("status", 500),
+	)
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	for key, want := range map[string]interface{}{
+		"msg":     "request …(truncated 26B)",
+		"body":    "xxxxxxxxxx…(truncated 2MB)",
+		"raw":     "0123456789…(truncated 3B)",
+		"payload": `{"body":"t…(truncated 17B)`,
+		"error":   "connection…(truncated 14B)",
+		"level":   "warn",
+		"status":  float64(500),
+	} {
+		if entry[key] != want {
+			t.Errorf("%s = %q, want %q", key, entry[key], want)
+		}
+	}
+
+	ids, _ := entry["ids"].([]interface{})
+	if len(ids) != 4 || ids[3] != "…(truncated 2 elements)" {
+		t.Errorf("ids = %v", entry["ids"])
+	}
+	names, _ := entry["names"].([]interface{})
+	if len(names) != 2 || names[1] != "a much lon…(truncated 8B)" {
+		t.Errorf("names = %v", entry["names"])
+	}
+	tree, _ := entry["tree"].(map[string]interface{})
+	if child, _ := tree["child"].(map[string]interface{}); child == nil || child["child"] != depthMarker {
+		t.Errorf("tree = %v", entry["tree"])
+	}
+}
+
+// verboseError has a detailed %+v format, like errors with stack traces
+type verboseError struct{}
+
+func (verboseError) Error() string { return "boom" }
+
+func (e verboseError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		io.WriteString(s, "boom\nSTACKTRACE "+strings.Repeat("frame ", 10))
+		return
+	}
+	io.WriteString(s, e.Error())
+}
+
+// causesError has several causes, like multierr errors
+type causesError []error
+
+func (e causesError) Error() string   { return "several errors" }
+func (e causesError) Errors() []error { return e }
+
+func TestLimitsErrorDetails(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Limits = &Limits{MaxStringLength: 20}
+	read := initializeToFile(t, config)
+
+	Warn("failed",
+		zap.Error(verboseError{}),
+		zap.NamedError("multi", causesError{errors.New("first cause"), errors.New(strings.Repeat("y", 30))}),
+	)
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry["error"] != "boom" || entry["errorVerbose"] != "boom\nSTACKTRACE fram…(truncated 56B)" {
+		t.Errorf("error = %q, errorVerbose = %q", entry["error"], entry["errorVerbose"])
+	}
+	causes, _ := entry["multiCauses"].([]interface{})
+	if len(causes) != 2 {
+		t.Fatalf("multiCauses = %v", entry["multiCauses"])
+	}
+	if second, _ := causes[1].(map[string]interface{}); second["error"] != strings.Repeat("y", 20)+"…(truncated 10B)" {
+		t.Errorf("second cause = %v", causes[1])
+	}
+}
+
+func TestLimitsApplyToEveryCore(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Limits = &Limits{MaxMessageBytes: 4, MaxStringLength: 4}
+	config.RecentBuffer = 10
+	read := initializeToFile(t, config)
+
+	var hooked []string
+	remove := AddHook(zapcore.InfoLevel, func(ent zapcore.Entry, fields []zapcore.Field) error {
+		hooked = append(hooked, ent.Message, fields[0].String)
+		return nil
+	})
+	Info("long message", zap.String("k", "long value"))
+	remove()
+	if len(hooked) != 2 || hooked[0] != "long…(truncated 8B)" || hooked[1] != "long…(truncated 6B)" {
+		t.Errorf("hook saw %q", hooked)
+	}
+	if entries := recent.snapshot(); len(entries) != 1 || entries[0].Msg != "long…(truncated 8B)" {
+		t.Errorf("recent entries = %+v", entries)
+	}
+
+	// Sampling still applies below the limits
+	for i := 0; i < 150; i++ {
+		Info("repeated")
+	}
+	if written := len(read()); written == 151 {
+		t.Error("entries were not sampled with limits set")
+	}
+}
+
+func TestLimitsMaxFields(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Limits = &Limits{MaxFields: 2}
+	read := initializeToFile(t, config)
+
+	Info("many", zap.Int("a", 1), zap.Int("b", 2), zap.Int("c", 3), zap.Int("d", 4))
+	Info("few", zap.Int("a", 1))
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if _, ok := entries[0]["c"]; ok || entries[0]["b"] != float64(2) || entries[0][TruncatedFieldsKey] != float64(2) {
+		t.Errorf("many = %v", entries[0])
+	}
+	if _, ok := entries[1][TruncatedFieldsKey]; ok {
+		t.Errorf("few = %v", entries[1])
+	}
+}
+
+func TestLimitsCountsTruncatedEntries(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Limits = &Limits{MaxStringLength: 4}
+	initializeToFile(t, config)
+
+	before := metrics.truncated.Load()
+	Info("kept", zap.String("k", "short"))
+	Info("kept", zap.String("k", "ok"))
+	With(zap.String("tenant", "acme-corp")).Info("context")
+	if got := metrics.truncated.Load() - before; got != 2 {
+		t.Errorf("truncated entries increased by %d, want 2", got)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1KB"},
+		{39<<20 + 1000, "39MB"},
+		{3 << 30, "3GB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
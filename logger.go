@@ -1,11 +1,17 @@
 // Package logger provides a simple and efficient logging interface built on top of zap.
-// It supports multiple log levels (DEBUG, INFO, WARN, ERROR, FATAL) and different environments.
+// It supports multiple log levels (TRACE, DEBUG, INFO, NOTICE, WARN, ERROR, CRITICAL, FATAL)
+// and different environments.
 package logger
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -42,27 +48,157 @@ func (e Environment) String() string {
 }
 
 var (
+	// global holds the logger built by Initialize. The logging functions
+	// load it without locking; Initialize replaces it as a whole.
+	global atomic.Pointer[globalLogger]
+
+	// mu serializes Initialize and guards the globals below
+	mu sync.RWMutex
+
+	// audit writes the records of Audit, or is nil if Config.AuditOutput is
+	// not set
+	audit *auditLog
+
+	// recent keeps the most recent entries for RecentHandler, or is nil if
+	// Config.RecentBuffer is zero
+	recent *recentBuffer
+
+	// currentLevel holds the minimum enabled level, shared by every logger
+	// built by Initialize so that SetLevel affects child loggers as well
+	currentLevel = &levelEnabler{}
+)
+
+// globalLogger is the state of the package-level logging functions
+type globalLogger struct {
 	logger *zap.Logger
 	sugar  *zap.SugaredLogger
-	mu     sync.RWMutex
+	// funcs is the logger used by the package-level functions, which report
+	// their caller themselves, see check
+	funcs *zap.Logger
+	// caller reports whether entries include their caller
+	caller bool
+	// env is the current environment setting
+	env Environment
+	// spanEvents records Error and above entries logged through the
+	// Context functions as span events
+	spanEvents bool
+	// release shuts down resources owned by the logger, such as background
+	// exporters, once it has been replaced
+	release []func()
+	// closeOutputs closes the outputs and error outputs after release
+	closeOutputs func()
+	// writers counts the package-level calls writing through the logger,
+	// so that it is closed after those that loaded it before it was
+	// replaced, see acquire
+	writers atomic.Int64
+}
 
-	// currentEnv holds the current environment setting
-	currentEnv Environment = Development
-)
+// acquire returns the global logger, or nil, counted as in use until done
+// is called. The count is taken before checking that the logger is still
+// current, so that close, which runs once it has been replaced, sees
+// every call that can still write to it.
+func acquire() *globalLogger {
+	for {
+		g := global.Load()
+		if g == nil {
+			return nil
+		}
+		g.writers.Add(1)
+		if global.Load() == g {
+			return g
+		}
+		g.writers.Add(-1)
+	}
+}
+
+// done ends a use of the logger started by acquire
+func (g *globalLogger) done() {
+	g.writers.Add(-1)
+}
+
+// enabled reports whether an entry at level may be written by the global
+// logger, so that disabled calls return without acquiring it. Entries at
+// DPanicLevel and above are checked regardless, as they may panic or exit.
+func enabled(level zapcore.Level) bool {
+	g := global.Load()
+	return g != nil && (levelAtLeast(level, zapcore.DPanicLevel) || g.funcs.Core().Enabled(level))
+}
 
 // Config holds logger configuration options
 type Config struct {
 	Environment Environment
 	Level       zapcore.Level
 	OutputPaths []string
-	Encoding    string // "json" or "console"
+	Encoding    string // "json", "console" or "pretty"
+	// Pretty configures the "pretty" encoding
+	Pretty *PrettyConfig
+
+	// ErrorOutputPaths receives the logger's own internal errors, such as
+	// failures to write to one of the OutputPaths. Defaults to stderr.
+	ErrorOutputPaths []string
+	// OnInternalError, if set, is called for every internal error reported
+	// by the logger in addition to writing it to ErrorOutputPaths.
+	OnInternalError func(error)
+
+	// OnFatal is called after a Fatal entry has been written and the exit
+	// hooks have run. Defaults to ExitOnFatal.
+	OnFatal FatalHandler
+	// ExitTimeout bounds how long exit hooks may run. Defaults to
+	// DefaultExitTimeout.
+	ExitTimeout time.Duration
+
+	// SpanEvents records entries at Error and above that are logged through
+	// the Context functions, e.g. ErrorContext, as events on the active
+	// OpenTelemetry span
+	SpanEvents bool
+
+	// ServiceName identifies the application to exporters, e.g. as the
+	// OpenTelemetry service.name resource attribute
+	ServiceName string
+	// OTLP, if set, additionally exports entries to an OpenTelemetry
+	// collector over OTLP/HTTP
+	OTLP *OTLPConfig
+	// Limits, if set, bounds the size of entries written to the outputs
+	Limits *Limits
+	// Sanitize, if set, escapes control characters in messages and string
+	// fields written with the console encoding and caps their length
+	Sanitize *SanitizeConfig
+
+	// EncryptionKeys encrypt outputs whose path has EncryptedScheme. The
+	// first key wraps the data keys of new file segments; the others are
+	// retired keys kept for DecryptLogFile and RewrapLogFile.
+	EncryptionKeys []EncryptionKey
+
+	// AuditOutput is the path, or "stdout"/"stderr", that Audit writes to.
+	// Audit records are kept apart from the other outputs and are never
+	// sampled or level filtered.
+	AuditOutput string
+	// AuditKey, if set, keys the audit hash chain with HMAC-SHA256 so that
+	// records cannot be forged without the key
+	AuditKey []byte
+
+	// ErrorSummary groups entries at Error and above by fingerprint for
+	// ErrorSummary. It is implied by Sentry.
+	ErrorSummary bool
+	// Sentry, if set, forwards the first entry of each new error
+	// fingerprint to a Sentry-compatible endpoint, see ErrorSummary
+	Sentry *SentryConfig
+
+	// Caller selects how the caller of an entry is reported. Defaults to
+	// CallerShort.
+	Caller CallerMode
+
+	// RecentBuffer, if positive, keeps the last RecentBuffer entries at all
+	// levels, regardless of Level, in memory to be served by RecentHandler
+	RecentBuffer int
 }
 
 // DefaultConfig returns a default configuration based on environment
 func DefaultConfig(env Environment) Config {
 	config := Config{
-		Environment: env,
-		OutputPaths: []string{"stdout"},
+		Environment:      env,
+		OutputPaths:      []string{"stdout"},
+		ErrorOutputPaths: []string{"stderr"},
 	}
 
 	switch env {
@@ -108,160 +244,397 @@ func Initialize(config Config) error {
 		zapConfig.OutputPaths = []string{} // No output for tests by default
 	}
 
-	zapConfig.Level = zap.NewAtomicLevelAt(config.Level)
+	// Level filtering is done by a levelCore so that custom levels are
+	// ordered correctly; the underlying core accepts everything.
 	zapConfig.Encoding = config.Encoding
+	if config.Environment == Development {
+		zapConfig.EncoderConfig.EncodeLevel = capitalLevelEncoder
+	} else {
+		zapConfig.EncoderConfig.EncodeLevel = lowercaseLevelEncoder
+	}
 	if len(config.OutputPaths) > 0 {
 		zapConfig.OutputPaths = config.OutputPaths
 	}
+	config.Caller.apply(&zapConfig)
+
+	// The logger is assembled here rather than by zapConfig.Build so that
+	// each output can be wrapped for metrics
+	var enc zapcore.Encoder
+	switch zapConfig.Encoding {
+	case "json":
+		enc = zapcore.NewJSONEncoder(zapConfig.EncoderConfig)
+	case "console":
+		enc = zapcore.NewConsoleEncoder(zapConfig.EncoderConfig)
+	case "pretty":
+		var pretty PrettyConfig
+		if config.Pretty != nil {
+			pretty = *config.Pretty
+		}
+		prettyEnc := newPrettyEncoder(pretty, prettyColor(pretty.Color, zapConfig.OutputPaths), time.Now())
+		prettyEnc.caller = config.Caller
+		enc = prettyEnc
+	default:
+		return fmt.Errorf("failed to build logger: unsupported encoding %q", zapConfig.Encoding)
+	}
+	if config.Sanitize != nil {
+		enc = newSanitizingEncoder(enc, *config.Sanitize, zapConfig.Encoding != "json")
+	}
+	sink, closeSink, err := openOutputs(zapConfig.OutputPaths, config.EncryptionKeys)
+	if err != nil {
+		return fmt.Errorf("failed to open outputs: %w", err)
+	}
 
-	var err error
-	logger, err = zapConfig.Build()
+	// Error outputs are opened here rather than by zap so that internal
+	// errors can be counted and reported to OnInternalError.
+	errorOutputPaths := config.ErrorOutputPaths
+	if len(errorOutputPaths) == 0 {
+		errorOutputPaths = []string{"stderr"}
+	}
+	zapConfig.ErrorOutputPaths = nil
+	errSink, closeErrSink, err := zap.Open(errorOutputPaths...)
 	if err != nil {
-		return fmt.Errorf("failed to build logger: %w", err)
+		closeSink()
+		return fmt.Errorf("failed to open error outputs: %w", err)
+	}
+	closeOutputs := func() {
+		closeSink()
+		closeErrSink()
+	}
+
+	errorOutput := newErrorReporter(errSink, config.OnInternalError)
+	opts := []zap.Option{
+		zap.ErrorOutput(errorOutput),
+		zap.WithFatalHook(newFatalHook(config.OnFatal, config.ExitTimeout)),
+	}
+	if zapConfig.Development {
+		opts = append(opts, zap.Development())
+	}
+	if !zapConfig.DisableCaller {
+		opts = append(opts, zap.AddCaller())
+	}
+
+	// fail releases what has been set up so far when Initialize fails
+	var release []func()
+	fail := func(err error) error {
+		for _, fn := range release {
+			fn()
+		}
+		closeOutputs()
+		return err
+	}
+	if config.OTLP != nil {
+		exporter, err := newOTLPExporter(*config.OTLP, otlpResource(config), errorOutput)
+		if err != nil {
+			return fail(fmt.Errorf("failed to create OTLP exporter: %w", err))
+		}
+		release = append(release, exporter.shutdown)
+		opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, newOTLPCore(exporter))
+		}))
+	}
+
+	// The audit log is reused if its output and key are unchanged so that
+	// the chain state is kept and the output is not opened twice
+	auditOutput := audit
+	if config.AuditOutput == "" {
+		auditOutput = nil
+	} else if auditOutput == nil || auditOutput.path != config.AuditOutput || !bytes.Equal(auditOutput.key, config.AuditKey) {
+		auditOutput, err = newAuditLog(config.AuditOutput, config.AuditKey)
+		if err != nil {
+			return fail(fmt.Errorf("failed to open audit output: %w", err))
+		}
+	}
+
+	var forwarder *sentryForwarder
+	if config.Sentry != nil {
+		forwarder, err = newSentryForwarder(*config.Sentry, config.Environment.String(), errorOutput)
+		if err != nil {
+			if auditOutput != nil && auditOutput != audit {
+				auditOutput.close()
+			}
+			return fail(fmt.Errorf("failed to create Sentry forwarder: %w", err))
+		}
+		release = append(release, forwarder.shutdown)
+	}
+
+	opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		// Sampling applies to the outputs and hooks, so that lazy fields of
+		// dropped entries are not evaluated, but the metrics count every
+		// entry
+		core = newHookCore(core, errorOutput)
+		if sampling := zapConfig.Sampling; sampling != nil {
+			core = newSamplerCore(core, time.Second, sampling.Initial, sampling.Thereafter,
+				zapcore.SamplerHook(countSampled))
+		}
+		return newLevelCore(newMetricsCore(core), currentLevel)
+	}))
+	// The recent buffer is reused if its size is unchanged so that
+	// reinitializing does not discard its history
+	buffer := recent
+	if config.RecentBuffer <= 0 {
+		buffer = nil
+	} else if buffer == nil || len(buffer.slots) != config.RecentBuffer {
+		buffer = newRecentBuffer(config.RecentBuffer)
+	}
+	if buffer != nil {
+		opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return newRecentCore(core, buffer)
+		}))
+	}
+	if !zapConfig.DisableStacktrace {
+		stackLevel := zapcore.ErrorLevel
+		if zapConfig.Development {
+			stackLevel = zapcore.WarnLevel
+		}
+		opts = append(opts, zap.AddStacktrace(minLevel(stackLevel)))
+	}
+
+	if config.Limits != nil {
+		// Added last so that it wraps every other core
+		opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return newLimitCore(core, *config.Limits, errorOutput)
+		}))
 	}
 
-	sugar = logger.Sugar()
-	currentEnv = config.Environment
+	newLogger := zap.New(zapcore.NewCore(enc, sink, allLevels), opts...)
+
+	recent = buffer
+	if audit != nil && audit != auditOutput {
+		// Records are no longer written to the replaced log, but Audit
+		// calls may still be syncing it
+		go audit.close()
+	}
+	audit = auditOutput
+	if audit != nil {
+		audit.errorOutput = errorOutput
+	}
+	sentry.Store(forwarder)
+	enableErrorGroups(config.ErrorSummary || forwarder != nil)
+	currentLevel.SetLevel(config.Level)
+	previous := global.Swap(&globalLogger{
+		logger:       newLogger,
+		sugar:        newLogger.Sugar(),
+		funcs:        funcsLogger(newLogger),
+		caller:       !zapConfig.DisableCaller,
+		env:          config.Environment,
+		spanEvents:   config.SpanEvents,
+		release:      release,
+		closeOutputs: closeOutputs,
+	})
+
+	// The previous logger is synced and its resources are released in the
+	// background so that a slow exporter cannot block reinitialization,
+	// once the package-level calls that loaded it before the swap are done.
+	// Loggers obtained from it with GetLogger or With must not be used
+	// after it is replaced.
+	if previous != nil {
+		go previous.close()
+	}
 
 	return nil
 }
 
+// close syncs the logger and releases its resources. The outputs are
+// closed last, as exporters may report errors while shutting down.
+func (g *globalLogger) close() {
+	for g.writers.Load() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_ = g.logger.Sync()
+	var wg sync.WaitGroup
+	for _, fn := range g.release {
+		wg.Add(1)
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
+	}
+	wg.Wait()
+	if g.closeOutputs != nil {
+		g.closeOutputs()
+	}
+}
+
 // SetEnvironment sets the environment and reinitializes the logger
 func SetEnvironment(env Environment) error {
 	return Initialize(DefaultConfig(env))
 }
 
-// SetLevel sets the log level dynamically
+// SetLevel sets the log level dynamically. The level may be lowered as well
+// as raised, and applies to loggers previously returned by With.
 func SetLevel(level zapcore.Level) {
-	mu.Lock()
-	defer mu.Unlock()
-	if logger != nil {
-		logger = logger.WithOptions(zap.IncreaseLevel(level))
-		sugar = logger.Sugar()
-	}
+	currentLevel.SetLevel(level)
+}
+
+// GetLevel returns the current minimum log level
+func GetLevel() zapcore.Level {
+	return currentLevel.Level()
 }
 
 // GetLogger returns the underlying zap logger for advanced usage
 func GetLogger() *zap.Logger {
-	mu.RLock()
-	defer mu.RUnlock()
-	return logger
+	if g := global.Load(); g != nil {
+		return g.logger
+	}
+	return nil
 }
 
 // GetSugar returns the sugared logger for easier usage
 func GetSugar() *zap.SugaredLogger {
-	mu.RLock()
-	defer mu.RUnlock()
-	return sugar
+	if g := global.Load(); g != nil {
+		return g.sugar
+	}
+	return nil
 }
 
 // Sync flushes any buffered log entries
 func Sync() error {
-	mu.RLock()
-	defer mu.RUnlock()
-	if logger != nil {
-		return logger.Sync()
+	if l := GetLogger(); l != nil {
+		return l.Sync()
 	}
 	return nil
 }
 
+// Trace logs a message at trace level with optional structured fields
+func Trace(msg string, fields ...zap.Field) {
+	logFields(TraceLevel, msg, fields)
+}
+
+// Tracef logs a formatted message at trace level
+func Tracef(template string, args ...interface{}) {
+	logf(TraceLevel, template, args)
+}
+
 // Debug logs a message at debug level with optional structured fields
 func Debug(msg string, fields ...zap.Field) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if logger != nil {
-		logger.Debug(msg, fields...)
-	}
+	logFields(zapcore.DebugLevel, msg, fields)
 }
 
 // Debugf logs a formatted message at debug level
 func Debugf(template string, args ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if sugar != nil {
-		sugar.Debugf(template, args...)
-	}
+	logf(zapcore.DebugLevel, template, args)
+}
+
+// Debugw logs a message at debug level with loosely typed key-value pairs
+func Debugw(msg string, keysAndValues ...interface{}) {
+	logw(zapcore.DebugLevel, msg, keysAndValues)
 }
 
 // Info logs a message at info level with optional structured fields
 func Info(msg string, fields ...zap.Field) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if logger != nil {
-		logger.Info(msg, fields...)
-	}
+	logFields(zapcore.InfoLevel, msg, fields)
 }
 
 // Infof logs a formatted message at info level
 func Infof(template string, args ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if sugar != nil {
-		sugar.Infof(template, args...)
-	}
+	logf(zapcore.InfoLevel, template, args)
+}
+
+// Infow logs a message at info level with loosely typed key-value pairs
+func Infow(msg string, keysAndValues ...interface{}) {
+	logw(zapcore.InfoLevel, msg, keysAndValues)
+}
+
+// Notice logs a message at notice level with optional structured fields
+func Notice(msg string, fields ...zap.Field) {
+	logFields(NoticeLevel, msg, fields)
+}
+
+// Noticef logs a formatted message at notice level
+func Noticef(template string, args ...interface{}) {
+	logf(NoticeLevel, template, args)
 }
 
 // Warn logs a message at warn level with optional structured fields
 func Warn(msg string, fields ...zap.Field) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if logger != nil {
-		logger.Warn(msg, fields...)
-	}
+	logFields(zapcore.WarnLevel, msg, fields)
 }
 
 // Warnf logs a formatted message at warn level
 func Warnf(template string, args ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if sugar != nil {
-		sugar.Warnf(template, args...)
-	}
+	logf(zapcore.WarnLevel, template, args)
+}
+
+// Warnw logs a message at warn level with loosely typed key-value pairs
+func Warnw(msg string, keysAndValues ...interface{}) {
+	logw(zapcore.WarnLevel, msg, keysAndValues)
 }
 
 // Error logs a message at error level with optional structured fields
 func Error(msg string, fields ...zap.Field) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if logger != nil {
-		logger.Error(msg, fields...)
-	}
+	logFields(zapcore.ErrorLevel, msg, fields)
 }
 
 // Errorf logs a formatted message at error level
 func Errorf(template string, args ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if sugar != nil {
-		sugar.Errorf(template, args...)
-	}
+	logf(zapcore.ErrorLevel, template, args)
+}
+
+// Errorw logs a message at error level with loosely typed key-value pairs
+func Errorw(msg string, keysAndValues ...interface{}) {
+	logw(zapcore.ErrorLevel, msg, keysAndValues)
+}
+
+// Critical logs a message at critical level with optional structured fields
+func Critical(msg string, fields ...zap.Field) {
+	logFields(CriticalLevel, msg, fields)
+}
+
+// Criticalf logs a formatted message at critical level
+func Criticalf(template string, args ...interface{}) {
+	logf(CriticalLevel, template, args)
+}
+
+// DPanic logs a message at dpanic level. In the Development environment it
+// then panics; in other environments it logs at error level and continues.
+func DPanic(msg string, fields ...zap.Field) {
+	logFields(dpanicLevel(), msg, fields)
 }
 
-// Fatal logs a message at fatal level and calls os.Exit(1)
+// DPanicf logs a formatted message like DPanic
+func DPanicf(template string, args ...interface{}) {
+	logf(dpanicLevel(), template, args)
+}
+
+// Panic logs a message at panic level and then panics
+func Panic(msg string, fields ...zap.Field) {
+	logFields(zapcore.PanicLevel, msg, fields)
+}
+
+// Panicf logs a formatted message at panic level and then panics
+func Panicf(template string, args ...interface{}) {
+	logf(zapcore.PanicLevel, template, args)
+}
+
+// Fatal logs a message at fatal level, runs the exit hooks and calls
+// Config.OnFatal, which defaults to os.Exit(1).
 // Use with caution - this will terminate the program
 func Fatal(msg string, fields ...zap.Field) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if logger != nil {
-		logger.Fatal(msg, fields...)
-	}
+	logFields(zapcore.FatalLevel, msg, fields)
 }
 
-// Fatalf logs a formatted message at fatal level and calls os.Exit(1)
+// Fatalf logs a formatted message at fatal level like Fatal
 func Fatalf(template string, args ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	if sugar != nil {
-		sugar.Fatalf(template, args...)
-	}
+	logf(zapcore.FatalLevel, template, args)
 }
 
-// With creates a child logger with additional structured context
+// Fatalw logs a message at fatal level with loosely typed key-value pairs
+// like Fatal
+func Fatalw(msg string, keysAndValues ...interface{}) {
+	logw(zapcore.FatalLevel, msg, keysAndValues)
+}
+
+// Panicw logs a message at panic level with loosely typed key-value pairs
+// and then panics
+func Panicw(msg string, keysAndValues ...interface{}) {
+	logw(zapcore.PanicLevel, msg, keysAndValues)
+}
+
+// With creates a child logger with additional structured context. Like
+// GetLogger, it reports the caller of its own methods.
 func With(fields ...zap.Field) *zap.Logger {
-	mu.RLock()
-	defer mu.RUnlock()
-	if logger != nil {
-		return logger.With(fields...)
+	if l := GetLogger(); l != nil {
+		return l.With(fields...)
 	}
 	return nil
 }
@@ -270,3 +643,166 @@ func With(fields ...zap.Field) *zap.Logger {
 func WithFields(fields ...zap.Field) *zap.Logger {
 	return With(fields...)
 }
+
+// dpanicLevel returns the level of DPanic entries: DPanicLevel, which
+// panics, in the Development environment and ErrorLevel elsewhere
+func dpanicLevel() zapcore.Level {
+	if g := global.Load(); g != nil && g.env == Development {
+		return zapcore.DPanicLevel
+	}
+	return zapcore.ErrorLevel
+}
+
+// formatMessage formats a template the same way the sugared logger does
+func formatMessage(template string, args []interface{}) string {
+	if len(args) == 0 {
+		return template
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// The functions below are called directly by the package-level logging
+// functions and call check directly, so that every entry reports the same
+// caller, see checkSkip.
+
+// logFields logs an entry with fields if level is enabled. Copying the
+// fields rather than passing them on keeps the caller's variadic slice on
+// the stack, so that a disabled call does not allocate: without the copy,
+// BenchmarkDebugDisabled allocates 64 bytes per call, while enabled calls
+// allocate the slice once either way.
+func logFields(level zapcore.Level, msg string, fields []zap.Field) {
+	if !enabled(level) {
+		return
+	}
+	g := acquire()
+	if g == nil {
+		return
+	}
+	defer g.done()
+	if ce := check(g.funcs, g.caller, level, msg); ce != nil {
+		ce.Write(slices.Clone(fields)...)
+	}
+}
+
+// logf logs a formatted message if level is enabled. Like the sugared
+// logger, it formats the message only if the entry may be written, so
+// that sampling applies to the formatted message.
+func logf(level zapcore.Level, template string, args []interface{}) {
+	if !enabled(level) {
+		return
+	}
+	g := acquire()
+	if g == nil {
+		return
+	}
+	defer g.done()
+	if ce := check(g.funcs, g.caller, level, formatMessage(template, args)); ce != nil {
+		ce.Write()
+	}
+}
+
+// logw logs a message with loosely typed key-value pairs if level is
+// enabled. Pairs that cannot be converted to fields are reported in
+// separate error entries, as the sugared logger does.
+func logw(level zapcore.Level, msg string, keysAndValues []interface{}) {
+	if !enabled(level) {
+		return
+	}
+	g := acquire()
+	if g == nil {
+		return
+	}
+	defer g.done()
+	ce := check(g.funcs, g.caller, level, msg)
+	if ce == nil {
+		return
+	}
+	fields, problems := keyValueFields(keysAndValues)
+	for _, problem := range problems {
+		if pe := check(g.funcs, g.caller, zapcore.ErrorLevel, problem.msg); pe != nil {
+			pe.Write(problem.field)
+		}
+	}
+	ce.Write(fields...)
+}
+
+// keyValueProblem is an error entry reporting key-value pairs that were
+// ignored
+type keyValueProblem struct {
+	msg   string
+	field zap.Field
+}
+
+// keyValueFields converts loosely typed key-value pairs to fields with the
+// rules of the sugared logger: fields are used as they are, the first
+// error without a key is added as "error", and other values are paired
+// with the string key before them. Pairs that break these rules are
+// returned as problems.
+func keyValueFields(keysAndValues []interface{}) ([]zap.Field, []keyValueProblem) {
+	if len(keysAndValues) == 0 {
+		return nil, nil
+	}
+	var (
+		fields    = make([]zap.Field, 0, len(keysAndValues))
+		problems  []keyValueProblem
+		invalid   invalidPairs
+		seenError bool
+	)
+	for i := 0; i < len(keysAndValues); {
+		switch v := keysAndValues[i].(type) {
+		case zap.Field:
+			fields = append(fields, v)
+			i++
+			continue
+		case error:
+			if !seenError {
+				seenError = true
+				fields = append(fields, zap.Error(v))
+			} else {
+				problems = append(problems, keyValueProblem{"Multiple errors without a key.", zap.Error(v)})
+			}
+			i++
+			continue
+		}
+		if i == len(keysAndValues)-1 {
+			problems = append(problems, keyValueProblem{"Ignored key without a value.", zap.Any("ignored", keysAndValues[i])})
+			break
+		}
+		key, value := keysAndValues[i], keysAndValues[i+1]
+		if s, ok := key.(string); ok {
+			fields = append(fields, zap.Any(s, value))
+		} else {
+			invalid = append(invalid, invalidPair{position: i, key: key, value: value})
+		}
+		i += 2
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, keyValueProblem{"Ignored key-value pairs with non-string keys.", zap.Array("invalid", invalid)})
+	}
+	return fields, problems
+}
+
+// invalidPair is a key-value pair with a non-string key
+type invalidPair struct {
+	position   int
+	key, value interface{}
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (p invalidPair) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("position", p.position)
+	zap.Any("key", p.key).AddTo(enc)
+	zap.Any("value", p.value).AddTo(enc)
+	return nil
+}
+
+type invalidPairs []invalidPair
+
+// MarshalLogArray implements zapcore.ArrayMarshaler
+func (ps invalidPairs) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	var err error
+	for _, p := range ps {
+		err = errors.Join(err, enc.AppendObject(p))
+	}
+	return err
+}
@@ -3,8 +3,13 @@ package logger
 import (
 	"bytes"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -112,7 +117,7 @@ func TestInitialize(t *testing.T) {
 }
 
 func TestSetEnvironment(t *testing.T) {
-	originalEnv := currentEnv
+	originalEnv := global.Load().env
 
 	// Test setting different environments
 	environments := []Environment{Development, Test, Staging, Production}
@@ -123,8 +128,8 @@ func TestSetEnvironment(t *testing.T) {
 			t.Errorf("SetEnvironment(%v) error = %v, want nil", env, err)
 		}
 
-		if currentEnv != env {
-			t.Errorf("currentEnv = %v, want %v", currentEnv, env)
+		if got := global.Load().env; got != env {
+			t.Errorf("env = %v, want %v", got, env)
 		}
 	}
 
@@ -169,12 +174,7 @@ func TestLoggingFunctions(t *testing.T) {
 	testLogger := zap.New(core)
 
 	// Replace the global logger temporarily
-	mu.Lock()
-	oldLogger := logger
-	oldSugar := sugar
-	logger = testLogger
-	sugar = testLogger.Sugar()
-	mu.Unlock()
+	previous := global.Swap(&globalLogger{logger: testLogger, sugar: testLogger.Sugar(), funcs: funcsLogger(testLogger)})
 
 	// Test all logging functions
 	Debug("debug message", zap.String("key", "value"))
@@ -187,10 +187,7 @@ func TestLoggingFunctions(t *testing.T) {
 	Errorf("error formatted %s", "message")
 
 	// Restore original logger
-	mu.Lock()
-	logger = oldLogger
-	sugar = oldSugar
-	mu.Unlock()
+	global.Store(previous)
 
 	// Check that logs were written
 	output := buf.String()
@@ -222,6 +219,109 @@ func TestLoggingFunctions(t *testing.T) {
 	}
 }
 
+func TestKeyValueFunctions(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Level = zapcore.DebugLevel
+	read := initializeToFile(t, config)
+
+	Debugw("debug message", "user_id", 42)
+	Infow("info message", "user_id", 42)
+	Warnw("warn message", "user_id", 42)
+	Errorw("error message", "user_id", 42, zap.String("typed", "field"))
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	for i, entry := range entries {
+		if entry["user_id"] != float64(42) {
+			t.Errorf("entry %d user_id = %v, want 42", i, entry["user_id"])
+		}
+	}
+	if entries[3]["typed"] != "field" {
+		t.Errorf("Errorw typed field = %v, want %q", entries[3]["typed"], "field")
+	}
+}
+
+func TestPanicw(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Panicw() did not panic")
+		}
+	}()
+	Panicw("panic message", "user_id", 42)
+}
+
+func TestPanic(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Panic", func() { Panic("panic message") }},
+		{"Panicf", func() { Panicf("panic %s", "formatted") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s() did not panic", tt.name)
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func TestDPanic(t *testing.T) {
+	tests := []struct {
+		env       Environment
+		wantPanic bool
+	}{
+		{Development, true},
+		{Test, false},
+		{Staging, false},
+		{Production, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env.String(), func(t *testing.T) {
+			config := DefaultConfig(tt.env)
+			config.Encoding = "json"
+			read := initializeToFile(t, config)
+
+			for _, fn := range []func(){
+				func() { DPanic("dpanic message") },
+				func() { DPanicf("dpanic %s", "formatted") },
+			} {
+				panicked := func() (panicked bool) {
+					defer func() { panicked = recover() != nil }()
+					fn()
+					return false
+				}()
+				if panicked != tt.wantPanic {
+					t.Errorf("panicked = %v, want %v", panicked, tt.wantPanic)
+				}
+			}
+
+			entries := decodeEntries(t, read())
+			if len(entries) != 2 {
+				t.Fatalf("got %d entries, want 2", len(entries))
+			}
+			// The development encoder config uses short keys
+			levelKey, wantLevel := "level", "error"
+			if tt.env == Development {
+				levelKey, wantLevel = "L", "DPANIC"
+			}
+			for _, entry := range entries {
+				if entry[levelKey] != wantLevel {
+					t.Errorf("level = %v, want %v", entry[levelKey], wantLevel)
+				}
+			}
+		})
+	}
+}
+
 func TestWith(t *testing.T) {
 	// Initialize logger
 	Initialize(DefaultConfig(Test))
@@ -277,14 +377,65 @@ func TestConcurrentAccess(t *testing.T) {
 	}
 }
 
+func TestInitializeWhileLogging(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	var writeErrors atomic.Int64
+	config := DefaultConfig(Staging)
+	config.OutputPaths = []string{path}
+	config.OnInternalError = func(error) { writeErrors.Add(1) }
+	if err := Initialize(config); err != nil {
+		t.Fatal(err)
+	}
+	defer Initialize(DefaultConfig(Test))
+
+	// Warn is enabled in both environments; every goroutine logs once
+	// before the logger is replaced
+	var wg, started sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			Warn("before reinitialization")
+			started.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					Warn("during reinitialization")
+					Warnf("during %s", "reinitialization")
+				}
+			}
+		}()
+	}
+	started.Wait()
+	for i := 0; i < 20; i++ {
+		config.Environment = []Environment{Staging, Production}[i%2]
+		if err := Initialize(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	// Every entry is written whole, whichever logger it went to
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodeEntries(t, strings.Split(strings.TrimSpace(string(data)), "\n"))
+
+	// Replaced loggers are closed once the calls writing to them are done
+	if n := writeErrors.Load(); n > 0 {
+		t.Errorf("%d write errors while reinitializing", n)
+	}
+}
+
 func TestNilLoggerHandling(t *testing.T) {
 	// Temporarily set logger to nil to test nil handling
-	mu.Lock()
-	oldLogger := logger
-	oldSugar := sugar
-	logger = nil
-	sugar = nil
-	mu.Unlock()
+	previous := global.Swap(nil)
 
 	// These should not panic
 	Debug("test")
@@ -295,6 +446,15 @@ func TestNilLoggerHandling(t *testing.T) {
 	Infof("test %s", "formatted")
 	Warnf("test %s", "formatted")
 	Errorf("test %s", "formatted")
+	Debugw("test", "key", "value")
+	Infow("test", "key", "value")
+	Warnw("test", "key", "value")
+	Errorw("test", "key", "value")
+	Panicw("test", "key", "value")
+	Panic("test")
+	Panicf("test %s", "formatted")
+	DPanic("test")
+	DPanicf("test %s", "formatted")
 
 	// Test other functions
 	if GetLogger() != nil {
@@ -308,10 +468,92 @@ func TestNilLoggerHandling(t *testing.T) {
 	}
 
 	// Restore logger
-	mu.Lock()
-	logger = oldLogger
-	sugar = oldSugar
-	mu.Unlock()
+	global.Store(previous)
+}
+
+func TestInitializeClosesOutputs(t *testing.T) {
+	fds := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skip("open files cannot be counted:", err)
+		}
+		return len(entries)
+	}
+	dir := t.TempDir()
+	defer Initialize(DefaultConfig(Test))
+	Initialize(DefaultConfig(Test))
+	time.Sleep(10 * time.Millisecond)
+	before := fds()
+
+	config := DefaultConfig(Staging)
+	config.OutputPaths = []string{filepath.Join(dir, "app.log")}
+	config.ErrorOutputPaths = []string{filepath.Join(dir, "errors.log")}
+	config.AuditOutput = filepath.Join(dir, "audit.log")
+	for i := 0; i < 10; i++ {
+		if err := Initialize(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Failed initializations close what they opened
+	failing := config
+	failing.AuditOutput = filepath.Join(dir, "other-audit.log")
+	failing.Sentry = &SentryConfig{DSN: "invalid"}
+	for i := 0; i < 10; i++ {
+		if err := Initialize(failing); err == nil {
+			t.Fatal("Initialize() with an invalid Sentry DSN succeeded")
+		}
+	}
+	Initialize(DefaultConfig(Test))
+
+	// Replaced loggers are closed in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for fds() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := fds(); after > before {
+		t.Errorf("%d open files after reinitializing, %d before", after, before)
+	}
+}
+
+// initializeToFile initializes the global logger to write to a temporary
+// file and returns a function reading back the lines written so far. The
+// default test configuration is restored when the test ends.
+func initializeToFile(t *testing.T, config Config) func() []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.log")
+	config.OutputPaths = []string{path}
+	if err := Initialize(config); err != nil {
+		t.Fatalf("Initialize() error = %v, want nil", err)
+	}
+	t.Cleanup(func() { Initialize(DefaultConfig(Test)) })
+
+	return func() []string {
+		t.Helper()
+		Sync()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading log output: %v", err)
+		}
+		output := strings.TrimSpace(string(data))
+		if output == "" {
+			return nil
+		}
+		return strings.Split(output, "\n")
+	}
+}
+
+// decodeEntries decodes JSON log lines
+func decodeEntries(t *testing.T, lines []string) []map[string]interface{} {
+	t.Helper()
+	entries := make([]map[string]interface{}, 0, len(lines))
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Line %d: Invalid JSON log entry: %v", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
 }
 
 // Benchmark tests
@@ -347,6 +589,50 @@ func BenchmarkDebugf(b *testing.B) {
 	}
 }
 
+// rwmutexLogger reproduces the previous design of the package-level
+// functions, which took a read lock on the global logger for every call
+type rwmutexLogger struct {
+	mu     sync.RWMutex
+	logger *zap.Logger
+}
+
+func (r *rwmutexLogger) Log(level zapcore.Level, msg string, fields ...zap.Field) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.logger != nil {
+		r.logger.Log(level, msg, fields...)
+	}
+}
+
+// BenchmarkParallel compares the throughput of the lock-free package-level
+// functions with the previous RWMutex design, e.g. with -cpu 1,8,32
+func BenchmarkParallel(b *testing.B) {
+	config := DefaultConfig(Test)
+	config.Level = zapcore.InfoLevel
+	Initialize(config)
+	defer Initialize(DefaultConfig(Test))
+	locked := &rwmutexLogger{logger: GetLogger()}
+
+	for _, level := range []zapcore.Level{zapcore.InfoLevel, zapcore.DebugLevel} {
+		b.Run(level.CapitalString()+"/atomic", func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					logFields(level, "parallel message", []zap.Field{zap.Int("attempt", 1)})
+				}
+			})
+		})
+		b.Run(level.CapitalString()+"/rwmutex", func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					locked.Log(level, "parallel message", zap.Int("attempt", 1))
+				}
+			})
+		})
+	}
+}
+
 // Example tests
 func ExampleDebug() {
 	Initialize(DefaultConfig(Development))
@@ -0,0 +1,254 @@
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Reasons for dropped entries reported by logger_dropped_entries_total
+const (
+	DropReasonSampled = "sampled"
+	DropReasonOTLP    = "otlp"
+)
+
+// metrics holds counters for every logger built by this package since the
+// process started, so that they stay monotonic across Initialize calls
+var metrics logMetrics
+
+// logMetrics counts log volume. Counters are created on first use and never
+// removed.
+type logMetrics struct {
+	entries    sync.Map // entryLabels -> *atomic.Uint64
+	sinkBytes  sync.Map // sink path -> *atomic.Uint64
+	sinkErrors sync.Map // sink path -> *atomic.Uint64
+	dropped    sync.Map // reason -> *atomic.Uint64
+	truncated  atomic.Uint64
+}
+
+// entryLabels are the labels of logger_entries_total
+type entryLabels struct {
+	level  string
+	logger string
+}
+
+// counter returns the counter for key in m, creating it if needed
+func counter(m *sync.Map, key interface{}) *atomic.Uint64 {
+	if c, ok := m.Load(key); ok {
+		return c.(*atomic.Uint64)
+	}
+	c, _ := m.LoadOrStore(key, new(atomic.Uint64))
+	return c.(*atomic.Uint64)
+}
+
+// countEntry counts an entry written at an enabled level
+func (m *logMetrics) countEntry(ent zapcore.Entry) {
+	counter(&m.entries, entryLabels{LevelName(ent.Level), ent.LoggerName}).Add(1)
+}
+
+// countDropped counts an entry that was not written for reason
+func (m *logMetrics) countDropped(reason string) {
+	counter(&m.dropped, reason).Add(1)
+}
+
+// countSampled is the sampler hook counting entries dropped by sampling
+func countSampled(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+	if dec&zapcore.LogDropped != 0 {
+		metrics.countDropped(DropReasonSampled)
+	}
+}
+
+// metricsCore counts the entries passed to the wrapped core. It is placed
+// inside the level filter, so only entries at enabled levels are counted.
+type metricsCore struct {
+	zapcore.Core
+}
+
+func newMetricsCore(core zapcore.Core) zapcore.Core {
+	return &metricsCore{Core: core}
+}
+
+// With implements zapcore.Core
+func (c *metricsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &metricsCore{Core: c.Core.With(fields)}
+}
+
+// Check implements zapcore.Core. The entry is counted even if the wrapped
+// core drops it, e.g. by sampling.
+func (c *metricsCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	ce = c.Core.Check(ent, ce)
+	return ce.AddCore(ent, entryCounter{c})
+}
+
+// Write implements zapcore.Core for entries written directly, such as those
+// flushed by a Scope
+func (c *metricsCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	metrics.countEntry(ent)
+	return c.Core.Write(ent, fields)
+}
+
+// entryCounter is added to checked entries by metricsCore so that writing
+// an entry counts it without writing it to the wrapped core again
+type entryCounter struct {
+	*metricsCore
+}
+
+// Write implements zapcore.Core
+func (e entryCounter) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	metrics.countEntry(ent)
+	return nil
+}
+
+// countingSink counts the bytes written to an output and failed writes
+type countingSink struct {
+	zapcore.WriteSyncer
+	bytes  *atomic.Uint64
+	errors *atomic.Uint64
+}
+
+// Write implements zapcore.WriteSyncer
+func (s countingSink) Write(p []byte) (int, error) {
+	n, err := s.WriteSyncer.Write(p)
+	s.bytes.Add(uint64(n))
+	if err != nil {
+		s.errors.Add(1)
+	}
+	return n, err
+}
+
+// openOutputs opens each output path like zap.Open, wrapped so that its
+// bytes and write errors are counted per path. Paths with EncryptedScheme
+// are encrypted with the first of keys. The returned function closes the
+// outputs.
+func openOutputs(paths []string, keys []EncryptionKey) (zapcore.WriteSyncer, func(), error) {
+	sinks := make([]zapcore.WriteSyncer, 0, len(paths))
+	closers := make([]func(), 0, len(paths))
+	for _, path := range paths {
+		sink, closeSink, err := openOutput(path, keys)
+		if err != nil {
+			for _, fn := range closers {
+				fn()
+			}
+			return nil, nil, err
+		}
+		closers = append(closers, closeSink)
+		sinks = append(sinks, countingSink{
+			WriteSyncer: sink,
+			bytes:       counter(&metrics.sinkBytes, path),
+			errors:      counter(&metrics.sinkErrors, path),
+		})
+	}
+	closeSinks := func() {
+		for _, fn := range closers {
+			fn()
+		}
+	}
+	return zapcore.NewMultiWriteSyncer(sinks...), closeSinks, nil
+}
+
+// openOutput opens a single output path
+func openOutput(path string, keys []EncryptionKey) (zapcore.WriteSyncer, func(), error) {
+	file, ok := encryptedPath(path)
+	if !ok {
+		return zap.Open(path)
+	}
+	if err := validateEncryptionKeys(keys); err != nil {
+		return nil, nil, fmt.Errorf("encrypted output %s: %w", file, err)
+	}
+	sink, err := openEncryptedSink(file, keys[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return sink, func() { sink.Close() }, nil
+}
+
+// WriteMetrics writes the logger's metrics in the Prometheus text
+// exposition format:
+//
+//   - logger_entries_total{level,logger}: entries logged at enabled levels
+//   - logger_sink_bytes_total{sink}: bytes written to each output path
+//   - logger_sink_write_errors_total{sink}: failed writes to each output path
+//   - logger_dropped_entries_total{reason}: entries dropped by sampling or
+//     because they could not be queued for OTLP export
+//   - logger_truncated_entries_total: entries with values truncated by
+//     Config.Limits
+//   - logger_internal_errors_total: see InternalErrors
+func WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	writeMetricHeader(bw, "logger_entries_total", "Log entries logged at enabled levels.")
+	for _, s := range collectSamples(&metrics.entries, func(key interface{}) string {
+		l := key.(entryLabels)
+		return fmt.Sprintf(`level="%s",logger="%s"`, escapeLabel(l.level), escapeLabel(l.logger))
+	}) {
+		fmt.Fprintf(bw, "logger_entries_total{%s} %d\n", s.labels, s.value)
+	}
+
+	for _, m := range []struct {
+		name, help, label string
+		values            *sync.Map
+	}{
+		{"logger_sink_bytes_total", "Bytes written to each output.", "sink", &metrics.sinkBytes},
+		{"logger_sink_write_errors_total", "Failed writes to each output.", "sink", &metrics.sinkErrors},
+		{"logger_dropped_entries_total", "Log entries that were not written, by reason.", "reason", &metrics.dropped},
+	} {
+		writeMetricHeader(bw, m.name, m.help)
+		for _, s := range collectSamples(m.values, func(key interface{}) string {
+			return fmt.Sprintf(`%s="%s"`, m.label, escapeLabel(key.(string)))
+		}) {
+			fmt.Fprintf(bw, "%s{%s} %d\n", m.name, s.labels, s.value)
+		}
+	}
+
+	writeMetricHeader(bw, "logger_truncated_entries_total", "Log entries with values truncated by Config.Limits.")
+	fmt.Fprintf(bw, "logger_truncated_entries_total %d\n", metrics.truncated.Load())
+
+	writeMetricHeader(bw, "logger_internal_errors_total", "Internal errors reported by the logger.")
+	fmt.Fprintf(bw, "logger_internal_errors_total %d\n", InternalErrors())
+
+	return bw.Flush()
+}
+
+// MetricsHandler serves WriteMetrics for scraping by Prometheus
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteMetrics(w)
+	})
+}
+
+// metricSample is a counter value with its formatted labels
+type metricSample struct {
+	labels string
+	value  uint64
+}
+
+// collectSamples returns the counters in m sorted by their labels
+func collectSamples(m *sync.Map, labels func(key interface{}) string) []metricSample {
+	var samples []metricSample
+	m.Range(func(key, value interface{}) bool {
+		samples = append(samples, metricSample{labels(key), value.(*atomic.Uint64).Load()})
+		return true
+	})
+	sort.Slice(samples, func(i, j int) bool { return samples[i].labels < samples[j].labels })
+	return samples
+}
+
+func writeMetricHeader(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+}
+
+// labelEscaper escapes label values as required by the exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
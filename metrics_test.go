@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// metricValue returns the value of a sample in the exposition format, or
+// -1 if it is missing
+func metricValue(t *testing.T, sample string) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteMetrics(&buf); err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, sample+" "); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				t.Fatalf("invalid value in %q", line)
+			}
+			return n
+		}
+	}
+	return -1
+}
+
+func TestMetricsEntriesByLevelAndLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.log")
+	config := DefaultConfig(Staging)
+	config.OutputPaths = []string{path}
+	if err := Initialize(config); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	t.Cleanup(func() { Initialize(DefaultConfig(Test)) })
+
+	infoSample := `logger_entries_total{level="info",logger="metrics\"test"}`
+	debugSample := `logger_entries_total{level="debug",logger="metrics\"test"}`
+	bytesSample := fmt.Sprintf(`logger_sink_bytes_total{sink="%s"}`, path)
+
+	named := GetLogger().Named(`metrics"test`)
+	named.Info("one")
+	named.Info("two")
+	named.Debug("disabled")
+	Sync()
+
+	if got := metricValue(t, infoSample); got != 2 {
+		t.Errorf("%s = %d, want 2", infoSample, got)
+	}
+	if got := metricValue(t, debugSample); got != -1 {
+		t.Errorf("%s = %d, want no sample", debugSample, got)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log output: %v", err)
+	}
+	if got := metricValue(t, bytesSample); got != len(data) {
+		t.Errorf("%s = %d, want %d", bytesSample, got, len(data))
+	}
+}
+
+func TestMetricsSampled(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Staging))
+
+	sample := `logger_dropped_entries_total{reason="sampled"}`
+	before := metricValue(t, sample)
+	if before < 0 {
+		before = 0
+	}
+
+	// The production sampler keeps the first 100 identical entries per
+	// second and every 100th after that
+	for i := 0; i < 150; i++ {
+		Info("repeated")
+	}
+
+	if got := metricValue(t, sample) - before; got != 50 {
+		t.Errorf("sampled entries = %d, want 50", got)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Staging))
+	Warn("counted", zap.Int("n", 1))
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE logger_entries_total counter\n",
+		`logger_entries_total{level="warn",logger=""} `,
+		"# TYPE logger_sink_write_errors_total counter\n",
+		"# TYPE logger_dropped_entries_total counter\n",
+		"logger_internal_errors_total ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// HTTP headers used for request correlation
+const (
+	TraceparentHeader   = "traceparent"
+	RequestIDHeader     = "X-Request-ID"
+	CorrelationIDHeader = "X-Correlation-ID"
+)
+
+// RequestIDKey is the field name of the request correlation ID
+const RequestIDKey = "request_id"
+
+// maxRequestIDLength bounds correlation IDs accepted from clients
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key for the request correlation ID
+type requestIDKey struct{}
+
+// ParseTraceparent parses a W3C Trace Context traceparent header value
+func ParseTraceparent(value string) (trace.SpanContext, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 {
+		return trace.SpanContext{}, errors.New("traceparent: too short")
+	}
+
+	version := value[0:2]
+	if !isLowerHex(version) || version == "ff" {
+		return trace.SpanContext{}, fmt.Errorf("traceparent: invalid version %q", version)
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if (version == "00" && len(value) != 55) || (len(value) > 55 && value[55] != '-') {
+		return trace.SpanContext{}, errors.New("traceparent: invalid length")
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return trace.SpanContext{}, errors.New("traceparent: invalid format")
+	}
+
+	traceIDHex, spanIDHex, flagsHex := value[3:35], value[36:52], value[53:55]
+	if !isLowerHex(traceIDHex) || !isLowerHex(spanIDHex) || !isLowerHex(flagsHex) {
+		return trace.SpanContext{}, errors.New("traceparent: fields must be lowercase hex")
+	}
+
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	var flags [1]byte
+	hex.Decode(traceID[:], []byte(traceIDHex))
+	hex.Decode(spanID[:], []byte(spanIDHex))
+	hex.Decode(flags[:], []byte(flagsHex))
+	if !traceID.IsValid() {
+		return trace.SpanContext{}, errors.New("traceparent: all-zero trace ID")
+	}
+	if !spanID.IsValid() {
+		return trace.SpanContext{}, errors.New("traceparent: all-zero parent ID")
+	}
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.TraceFlags(flags[0]) & trace.FlagsSampled,
+		Remote:     true,
+	}), nil
+}
+
+// FormatTraceparent formats a span context as a version 00 traceparent
+// header value
+func FormatTraceparent(sc trace.SpanContext) string {
+	return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+}
+
+// RequestIDFromContext returns the correlation ID of the request being
+// handled, as set by Middleware
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware logs every request handled by next and makes a request-scoped
+// logger available through FromContext and the Context functions.
+//
+// The trace context is taken from an active span (e.g. set by OpenTelemetry
+// instrumentation), else from a valid traceparent header, in which case a
+// new span ID is assigned to this request, else a new trace is started. The
+// correlation ID is taken from X-Request-ID or X-Correlation-ID, or
+// generated, and echoed in the response. Completed requests are logged
+// without the caller, which would be in net/http.
+func Middleware(next http.Handler) http.Handler {
+	return middleware(next, false, nil)
+}
+
+// ScopedMiddleware is like Middleware, but the request-scoped logger buffers
+// entries below the logger's level in a Scope for the duration of the
+// request. They are written if an entry at the scope's trigger level is
+// logged, including the completion entry of a request that failed with a
+// 5xx status, and discarded otherwise.
+func ScopedMiddleware(opts ...ScopeOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return middleware(next, true, opts)
+	}
+}
+
+func middleware(next http.Handler, scoped bool, scopeOpts []ScopeOption) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+
+		var fields []zap.Field
+		if sc := trace.SpanContextFromContext(ctx); !sc.IsValid() {
+			sc, parent := serverSpanContext(r.Header.Get(TraceparentHeader))
+			ctx = trace.ContextWithSpanContext(ctx, sc)
+			if parent.IsValid() {
+				fields = append(fields, zap.String("parent_span_id", parent.SpanID().String()))
+			}
+		}
+
+		header, requestID := requestIDFromHeaders(r.Header)
+		w.Header().Set(header, requestID)
+		ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+
+		if base := contextLogger(ctx); base != nil {
+			ctx = NewContext(ctx, base.With(zap.String(RequestIDKey, requestID)))
+		}
+		if scoped {
+			var scope *Scope
+			ctx, scope = NewScope(ctx, scopeOpts...)
+			defer scope.End()
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		fields = append(fields,
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", rec.status),
+			zap.Int64("bytes", rec.bytes),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("user_agent", r.UserAgent()),
+		)
+		logContext(ctx, levelForStatus(rec.status), "request completed", fields, false)
+	})
+}
+
+// serverSpanContext returns the span context for a request: a child of the
+// incoming traceparent if it is valid, otherwise a new trace
+func serverSpanContext(traceparent string) (sc, parent trace.SpanContext) {
+	if traceparent != "" {
+		if p, err := ParseTraceparent(traceparent); err == nil {
+			return trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    p.TraceID(),
+				SpanID:     newSpanID(),
+				TraceFlags: p.TraceFlags(),
+			}), p
+		}
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    newTraceID(),
+		SpanID:     newSpanID(),
+		TraceFlags: trace.FlagsSampled,
+	}), trace.SpanContext{}
+}
+
+// requestIDFromHeaders returns the correlation ID sent by the client and the
+// header it came in, or a new ID to be sent as X-Request-ID
+func requestIDFromHeaders(h http.Header) (header, id string) {
+	for _, name := range []string{RequestIDHeader, CorrelationIDHeader} {
+		if id := h.Get(name); validRequestID(id) {
+			return name, id
+		}
+	}
+	return RequestIDHeader, newRequestID()
+}
+
+// validRequestID rejects empty, overly long and non-printable correlation
+// IDs so that clients cannot inject content into logs or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// levelForStatus chooses the level for a completed request
+func levelForStatus(status int) zapcore.Level {
+	switch {
+	case status >= 500:
+		return zapcore.ErrorLevel
+	case status >= 400:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush implements http.Flusher for handlers that assert it, e.g. to
+// stream events. It does nothing if the underlying writer cannot flush.
+func (r *statusRecorder) Flush() {
+	r.wroteHeader = true
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker for handlers that assert it, e.g. to
+// upgrade to WebSocket. A hijacked request is logged with status 101, as
+// its response is no longer written through the recorder.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func newTraceID() trace.TraceID {
+	var id trace.TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() trace.SpanID {
+	var id trace.SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// isLowerHex reports whether s consists of lowercase hex digits only
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false},
+		{"future version with extra fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"empty", "", true},
+		{"version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"version 00 with extra fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", true},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", true},
+		{"zero parent ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", true},
+		{"bad separator", "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"non hex", "00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseTraceparent(%q) error = nil, want error", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTraceparent(%q) error = %v", tt.value, err)
+			}
+			if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID().String() != "00f067aa0ba902b7" {
+				t.Errorf("ParseTraceparent(%q) = %s %s", tt.value, sc.TraceID(), sc.SpanID())
+			}
+			if !sc.IsRemote() {
+				t.Error("parsed span context is not remote")
+			}
+			if got := FormatTraceparent(sc); tt.value[:2] == "00" && got != tt.value {
+				t.Errorf("FormatTraceparent() = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestMiddlewareTraceparent(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	var handlerSC trace.SpanContext
+	var handlerRequestID string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSC = trace.SpanContextFromContext(r.Context())
+		handlerRequestID = RequestIDFromContext(r.Context())
+		InfoContext(r.Context(), "handling")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/pot", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(CorrelationIDHeader, "corr-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if handlerSC.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("handler trace ID = %s, want the incoming trace ID", handlerSC.TraceID())
+	}
+	if !handlerSC.SpanID().IsValid() || handlerSC.SpanID().String() == "00f067aa0ba902b7" {
+		t.Errorf("handler span ID = %s, want a new span ID", handlerSC.SpanID())
+	}
+	if handlerRequestID != "corr-123" {
+		t.Errorf("RequestIDFromContext() = %q, want corr-123", handlerRequestID)
+	}
+	if got := rec.Header().Get(CorrelationIDHeader); got != "corr-123" {
+		t.Errorf("response %s = %q, want corr-123", CorrelationIDHeader, got)
+	}
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if entry[TraceIDKey] != handlerSC.TraceID().String() || entry[SpanIDKey] != handlerSC.SpanID().String() {
+			t.Errorf("entry trace fields = %v %v", entry[TraceIDKey], entry[SpanIDKey])
+		}
+		if entry[RequestIDKey] != "corr-123" {
+			t.Errorf("entry request_id = %v, want corr-123", entry[RequestIDKey])
+		}
+	}
+
+	completed := entries[1]
+	if completed["level"] != "warn" || completed["status"] != float64(http.StatusTeapot) || completed["bytes"] != float64(15) {
+		t.Errorf("completion entry = %v", completed)
+	}
+	if completed["parent_span_id"] != "00f067aa0ba902b7" || completed["path"] != "/pot" {
+		t.Errorf("completion entry = %v", completed)
+	}
+	// The completion entry is logged from net/http's call of the handler
+	if caller, _ := entries[0]["caller"].(string); !strings.Contains(caller, "middleware_test.go:") {
+		t.Errorf("handler entry caller = %q, want the handler", caller)
+	}
+	if caller, ok := completed["caller"]; ok {
+		t.Errorf("completion entry caller = %v, want none", caller)
+	}
+}
+
+func TestMiddlewareGeneratesIDs(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	var handlerSC trace.SpanContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSC = trace.SpanContextFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, "invalid")
+	req.Header.Set(RequestIDHeader, strings.Repeat("x", maxRequestIDLength+1))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !handlerSC.IsValid() {
+		t.Fatal("handler has no valid span context")
+	}
+	requestID := rec.Header().Get(RequestIDHeader)
+	if len(requestID) != 32 {
+		t.Errorf("response %s = %q, want a generated ID", RequestIDHeader, requestID)
+	}
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if entries[0][TraceIDKey] != handlerSC.TraceID().String() || entries[0][RequestIDKey] != requestID {
+		t.Errorf("completion entry = %v", entries[0])
+	}
+	if _, ok := entries[0]["parent_span_id"]; ok {
+		t.Error("completion entry has a parent_span_id for an invalid traceparent")
+	}
+	if entries[0]["level"] != "info" {
+		t.Errorf("level = %v, want info", entries[0]["level"])
+	}
+}
+
+func TestMiddlewareFlusher(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("response writer does not implement http.Flusher")
+		}
+		w.Write([]byte("data: 1\n\n"))
+		flusher.Flush()
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	if !rec.Flushed {
+		t.Error("Flush was not passed to the underlying writer")
+	}
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 || entries[0]["status"] != float64(http.StatusOK) || entries[0]["bytes"] != float64(9) {
+		t.Errorf("entries = %v", entries)
+	}
+}
+
+func TestMiddlewareHijacker(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	server := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("response writer does not implement http.Hijacker")
+			return
+		}
+		conn, rw, err := hijacker.Hijack()
+		if err != nil {
+			t.Errorf("Hijack() error = %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		rw.Flush()
+	})))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want 101", resp.StatusCode)
+	}
+
+	// The entry is logged once the handler returns
+	var entries []map[string]interface{}
+	for deadline := time.Now().Add(5 * time.Second); len(entries) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		entries = decodeEntries(t, read())
+	}
+	if len(entries) != 1 || entries[0]["status"] != float64(http.StatusSwitchingProtocols) {
+		t.Errorf("entries = %v, want one with status 101", entries)
+	}
+}
@@ -0,0 +1,600 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// OTLP protocols supported by OTLPConfig.Protocol
+const (
+	OTLPProtobuf = "http/protobuf"
+	OTLPJSON     = "http/json"
+)
+
+// Defaults for OTLPConfig
+const (
+	DefaultOTLPBatchSize     = 512
+	DefaultOTLPQueueSize     = 2048
+	DefaultOTLPFlushInterval = time.Second
+	DefaultOTLPTimeout       = 10 * time.Second
+	DefaultOTLPMaxRetries    = 3
+	DefaultOTLPRetryBackoff  = 500 * time.Millisecond
+)
+
+// otlpScopeName is the instrumentation scope reported with every record
+const otlpScopeName = "github.com/kingrain94/logger"
+
+// OTLPConfig configures exporting log entries to an OpenTelemetry collector
+// using the OTLP/HTTP logs protocol. Entries are converted to the OTel Logs
+// data model, batched in the background and retried on transient failures.
+// Export failures are reported as internal errors.
+type OTLPConfig struct {
+	// Endpoint is the full URL of the logs endpoint,
+	// e.g. "http://localhost:4318/v1/logs"
+	Endpoint string
+	// Protocol is OTLPProtobuf (default) or OTLPJSON
+	Protocol string
+	// Headers are added to every export request, e.g. for authentication
+	Headers map[string]string
+	// ResourceAttributes are added to service.name and
+	// deployment.environment, which come from Config
+	ResourceAttributes map[string]string
+
+	// BatchSize is the maximum number of records per request
+	BatchSize int
+	// QueueSize bounds the records waiting to be exported; entries logged
+	// while the queue is full are dropped
+	QueueSize int
+	// FlushInterval is how often a partial batch is exported
+	FlushInterval time.Duration
+	// Timeout bounds each export request
+	Timeout time.Duration
+	// MaxRetries is the number of retries after a failed request. Negative
+	// disables retries.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled each retry
+	RetryBackoff time.Duration
+
+	// Client is the HTTP client used for export requests. Defaults to a
+	// client without a timeout; Timeout applies per request.
+	Client *http.Client
+}
+
+// withDefaults returns a copy of c with zero values replaced by defaults
+func (c OTLPConfig) withDefaults() OTLPConfig {
+	if c.Protocol == "" {
+		c.Protocol = OTLPProtobuf
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultOTLPBatchSize
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultOTLPQueueSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultOTLPFlushInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultOTLPTimeout
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultOTLPMaxRetries
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = DefaultOTLPRetryBackoff
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{}
+	}
+	return c
+}
+
+// otlpValue is an attribute value in the OTel data model: string, bool,
+// int64, float64, []byte, []otlpValue or []otlpKeyValue
+type otlpValue interface{}
+
+// otlpKeyValue is an attribute
+type otlpKeyValue struct {
+	Key   string
+	Value otlpValue
+}
+
+// otlpRecord is a log record in the OTel Logs data model
+type otlpRecord struct {
+	Time           time.Time
+	ObservedTime   time.Time
+	SeverityNumber int32
+	SeverityText   string
+	Body           string
+	Attributes     []otlpKeyValue
+	TraceID        []byte
+	SpanID         []byte
+	Flags          uint32
+}
+
+// otlpCore is a zapcore.Core that converts entries to OTel log records and
+// hands them to an exporter. Level filtering is left to the wrapping core.
+type otlpCore struct {
+	exporter *otlpExporter
+	fields   []zapcore.Field
+}
+
+func newOTLPCore(exporter *otlpExporter) zapcore.Core {
+	return &otlpCore{exporter: exporter}
+}
+
+// Enabled implements zapcore.Core
+func (c *otlpCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+// With implements zapcore.Core
+func (c *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &otlpCore{exporter: c.exporter}
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return clone
+}
+
+// Check implements zapcore.Core
+func (c *otlpCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+// Write implements zapcore.Core
+func (c *otlpCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.exporter.enqueue(newOTLPRecord(ent, c.fields, fields))
+	return nil
+}
+
+// Sync implements zapcore.Core by exporting everything queued so far
+func (c *otlpCore) Sync() error {
+	return c.exporter.flush()
+}
+
+// newOTLPRecord converts an entry to a log record. Trace correlation fields
+// become the record's trace context instead of attributes.
+func newOTLPRecord(ent zapcore.Entry, fieldSets ...[]zapcore.Field) otlpRecord {
+	record := otlpRecord{
+		Time:           ent.Time,
+		ObservedTime:   time.Now(),
+		SeverityNumber: otlpSeverity(ent.Level),
+		SeverityText:   strings.ToUpper(LevelName(ent.Level)),
+		Body:           ent.Message,
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, fields := range fieldSets {
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+	}
+	resolveLazy(enc.Fields)
+
+	if ent.LoggerName != "" {
+		record.Attributes = append(record.Attributes, otlpKeyValue{"logger.name", ent.LoggerName})
+	}
+	if ent.Caller.Defined {
+		record.Attributes = append(record.Attributes,
+			otlpKeyValue{"code.filepath", ent.Caller.File},
+			otlpKeyValue{"code.lineno", int64(ent.Caller.Line)},
+			otlpKeyValue{"code.function", ent.Caller.Function},
+		)
+	}
+	if ent.Stack != "" {
+		record.Attributes = append(record.Attributes, otlpKeyValue{"exception.stacktrace", ent.Stack})
+	}
+
+	for _, key := range sortedKeys(enc.Fields) {
+		value := enc.Fields[key]
+		switch key {
+		case TraceIDKey:
+			record.TraceID = decodeHexID(value, 16)
+			continue
+		case SpanIDKey:
+			record.SpanID = decodeHexID(value, 8)
+			continue
+		case TraceFlagsKey:
+			if s, ok := value.(string); ok {
+				if flags, err := strconv.ParseUint(s, 16, 8); err == nil {
+					record.Flags = uint32(flags)
+				}
+			}
+			continue
+		}
+		record.Attributes = append(record.Attributes, otlpKeyValue{key, toOTLPValue(value)})
+	}
+
+	return record
+}
+
+// otlpSeverity maps levels to OTel severity numbers
+func otlpSeverity(l zapcore.Level) int32 {
+	switch l {
+	case TraceLevel:
+		return 1 // TRACE
+	case zapcore.DebugLevel:
+		return 5 // DEBUG
+	case zapcore.InfoLevel:
+		return 9 // INFO
+	case NoticeLevel:
+		return 10 // INFO2
+	case zapcore.WarnLevel:
+		return 13 // WARN
+	case zapcore.ErrorLevel:
+		return 17 // ERROR
+	case CriticalLevel:
+		return 18 // ERROR2
+	case zapcore.DPanicLevel:
+		return 19 // ERROR3
+	case zapcore.PanicLevel, zapcore.FatalLevel:
+		return 21 // FATAL
+	default:
+		return 0 // UNSPECIFIED
+	}
+}
+
+// decodeHexID decodes a hex trace or span ID of the given size
+func decodeHexID(value interface{}, size int) []byte {
+	s, ok := value.(string)
+	if !ok || len(s) != size*2 {
+		return nil
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	return id
+}
+
+// toOTLPValue converts a value produced by zapcore.MapObjectEncoder to an
+// attribute value
+func toOTLPValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return v
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case uint:
+		return uintValue(uint64(v))
+	case uint64:
+		return uintValue(v)
+	case uint32:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uintptr:
+		return uintValue(uint64(v))
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case []byte:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case map[string]interface{}:
+		kvs := make([]otlpKeyValue, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			kvs = append(kvs, otlpKeyValue{key, toOTLPValue(v[key])})
+		}
+		return kvs
+	case []interface{}:
+		values := make([]otlpValue, len(v))
+		for i, elem := range v {
+			values[i] = toOTLPValue(elem)
+		}
+		return values
+	case fmt.Stringer:
+		return v.String()
+	default:
+		// Reflected values are round-tripped through JSON to get a
+		// structure of maps and slices
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return string(data)
+		}
+		if _, ok := generic.(float64); ok {
+			return string(data)
+		}
+		return toOTLPValue(generic)
+	}
+}
+
+// uintValue converts an unsigned integer, falling back to a string for
+// values that do not fit an int64
+func uintValue(v uint64) otlpValue {
+	if v > math.MaxInt64 {
+		return strconv.FormatUint(v, 10)
+	}
+	return int64(v)
+}
+
+// otlpExporter batches records and sends them to the collector from a
+// background goroutine
+type otlpExporter struct {
+	config      OTLPConfig
+	resource    []otlpKeyValue
+	errorOutput zapcore.WriteSyncer
+
+	queue   chan otlpRecord
+	flushes chan chan error
+	stop    chan struct{}
+	stopped chan struct{}
+	closed  atomic.Bool
+	close   sync.Once
+
+	dropped atomic.Uint64
+}
+
+func newOTLPExporter(config OTLPConfig, resource []otlpKeyValue, errorOutput zapcore.WriteSyncer) (*otlpExporter, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("otlp: missing endpoint")
+	}
+	config = config.withDefaults()
+	if config.Protocol != OTLPProtobuf && config.Protocol != OTLPJSON {
+		return nil, fmt.Errorf("otlp: unsupported protocol %q", config.Protocol)
+	}
+
+	e := &otlpExporter{
+		config:      config,
+		resource:    resource,
+		errorOutput: errorOutput,
+		queue:       make(chan otlpRecord, config.QueueSize),
+		flushes:     make(chan chan error),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// otlpResource returns the resource attributes for a configuration
+func otlpResource(config Config) []otlpKeyValue {
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "unknown_service"
+	}
+	resource := []otlpKeyValue{
+		{"service.name", serviceName},
+		{"deployment.environment", config.Environment.String()},
+	}
+	if config.OTLP != nil {
+		for _, key := range sortedKeys(config.OTLP.ResourceAttributes) {
+			resource = append(resource, otlpKeyValue{key, config.OTLP.ResourceAttributes[key]})
+		}
+	}
+	return resource
+}
+
+// enqueue adds a record without blocking, dropping it if the queue is full
+// or the exporter has been closed
+func (e *otlpExporter) enqueue(record otlpRecord) {
+	if e.closed.Load() {
+		e.dropped.Add(1)
+		metrics.countDropped(DropReasonOTLP)
+		return
+	}
+	select {
+	case e.queue <- record:
+	default:
+		e.dropped.Add(1)
+		metrics.countDropped(DropReasonOTLP)
+	}
+}
+
+// flush exports everything queued so far and waits for the result
+func (e *otlpExporter) flush() error {
+	if e.closed.Load() {
+		return nil
+	}
+	result := make(chan error, 1)
+	select {
+	case e.flushes <- result:
+		return <-result
+	case <-e.stopped:
+		return nil
+	}
+}
+
+// shutdown exports the remaining records and stops the exporter
+func (e *otlpExporter) shutdown() {
+	e.close.Do(func() {
+		e.closed.Store(true)
+		close(e.stop)
+		<-e.stopped
+	})
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpRecord, 0, e.config.BatchSize)
+	send := func() error {
+		err := e.drain(&batch)
+		if dropped := e.dropped.Swap(0); dropped > 0 {
+			e.reportError(fmt.Errorf("dropped %d entries: queue full", dropped))
+		}
+		return err
+	}
+
+	for {
+		select {
+		case record := <-e.queue:
+			batch = append(batch, record)
+			if len(batch) >= e.config.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case result := <-e.flushes:
+			result <- send()
+		case <-e.stop:
+			send()
+			return
+		}
+	}
+}
+
+// drain exports the pending batch and everything currently in the queue
+func (e *otlpExporter) drain(batch *[]otlpRecord) error {
+	var errs []error
+	for {
+	fill:
+		for len(*batch) < e.config.BatchSize {
+			select {
+			case record := <-e.queue:
+				*batch = append(*batch, record)
+			default:
+				break fill
+			}
+		}
+		if len(*batch) == 0 {
+			return errors.Join(errs...)
+		}
+		if err := e.export(*batch); err != nil {
+			e.reportError(err)
+			errs = append(errs, err)
+		}
+		*batch = (*batch)[:0]
+	}
+}
+
+// export sends one batch, retrying transient failures
+func (e *otlpExporter) export(records []otlpRecord) error {
+	var (
+		body        []byte
+		contentType string
+	)
+	if e.config.Protocol == OTLPJSON {
+		body, contentType = encodeOTLPJSON(e.resource, records), "application/json"
+	} else {
+		body, contentType = encodeOTLPProtobuf(e.resource, records), "application/x-protobuf"
+	}
+
+	backoff := e.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := e.send(body, contentType)
+		if err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= e.config.MaxRetries {
+			return fmt.Errorf("failed to export %d entries: %w", len(records), err)
+		}
+
+		delay := backoff
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		backoff *= 2
+
+		select {
+		case <-time.After(delay):
+		case <-e.stop:
+			return fmt.Errorf("failed to export %d entries: %w", len(records), err)
+		}
+	}
+}
+
+// permanentError is an export failure that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (p permanentError) Error() string { return p.err.Error() }
+func (p permanentError) Unwrap() error { return p.err }
+
+// send performs one export request. It returns the delay requested by the
+// collector through Retry-After, if any.
+func (e *otlpExporter) send(body []byte, contentType string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+
+	err = fmt.Errorf("collector responded %s", resp.Status)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		var retryAfter time.Duration
+		if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return retryAfter, err
+	default:
+		return 0, permanentError{err}
+	}
+}
+
+// reportError writes an export failure to the logger's error output, which
+// counts it as an internal error
+func (e *otlpExporter) reportError(err error) {
+	fmt.Fprintf(e.errorOutput, "%v otlp export error: %v\n", time.Now().UTC(), err)
+	_ = e.errorOutput.Sync()
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,227 @@
+package logger
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers from opentelemetry/proto/collector/logs/v1,
+// opentelemetry/proto/logs/v1 and opentelemetry/proto/common/v1
+const (
+	exportRequestResourceLogs = 1
+
+	resourceLogsResource  = 1
+	resourceLogsScopeLogs = 2
+
+	resourceAttributes = 1
+
+	scopeLogsScope      = 1
+	scopeLogsLogRecords = 2
+
+	scopeName = 1
+
+	logRecordTimeUnixNano         = 1
+	logRecordSeverityNumber       = 2
+	logRecordSeverityText         = 3
+	logRecordBody                 = 5
+	logRecordAttributes           = 6
+	logRecordFlags                = 8
+	logRecordTraceID              = 9
+	logRecordSpanID               = 10
+	logRecordObservedTimeUnixNano = 11
+
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	anyValueString = 1
+	anyValueBool   = 2
+	anyValueInt    = 3
+	anyValueDouble = 4
+	anyValueArray  = 5
+	anyValueKVList = 6
+	anyValueBytes  = 7
+
+	arrayValueValues  = 1
+	kvListValueValues = 1
+)
+
+// encodeOTLPProtobuf encodes records as an ExportLogsServiceRequest
+func encodeOTLPProtobuf(resource []otlpKeyValue, records []otlpRecord) []byte {
+	var res []byte
+	for _, kv := range resource {
+		res = appendMessage(res, resourceAttributes, appendKeyValue(nil, kv))
+	}
+
+	var scopeLogs []byte
+	scopeLogs = appendMessage(scopeLogs, scopeLogsScope, protowire.AppendString(
+		protowire.AppendTag(nil, scopeName, protowire.BytesType), otlpScopeName))
+	for _, record := range records {
+		scopeLogs = appendMessage(scopeLogs, scopeLogsLogRecords, appendLogRecord(nil, record))
+	}
+
+	var resourceLogs []byte
+	resourceLogs = appendMessage(resourceLogs, resourceLogsResource, res)
+	resourceLogs = appendMessage(resourceLogs, resourceLogsScopeLogs, scopeLogs)
+
+	return appendMessage(nil, exportRequestResourceLogs, resourceLogs)
+}
+
+// appendMessage appends an embedded message field
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendLogRecord(b []byte, record otlpRecord) []byte {
+	b = protowire.AppendTag(b, logRecordTimeUnixNano, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(record.Time.UnixNano()))
+	b = protowire.AppendTag(b, logRecordSeverityNumber, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(record.SeverityNumber))
+	b = protowire.AppendTag(b, logRecordSeverityText, protowire.BytesType)
+	b = protowire.AppendString(b, record.SeverityText)
+	b = appendMessage(b, logRecordBody, appendAnyValue(nil, record.Body))
+	for _, kv := range record.Attributes {
+		b = appendMessage(b, logRecordAttributes, appendKeyValue(nil, kv))
+	}
+	if record.Flags != 0 {
+		b = protowire.AppendTag(b, logRecordFlags, protowire.Fixed32Type)
+		b = protowire.AppendFixed32(b, record.Flags)
+	}
+	if len(record.TraceID) > 0 {
+		b = protowire.AppendTag(b, logRecordTraceID, protowire.BytesType)
+		b = protowire.AppendBytes(b, record.TraceID)
+	}
+	if len(record.SpanID) > 0 {
+		b = protowire.AppendTag(b, logRecordSpanID, protowire.BytesType)
+		b = protowire.AppendBytes(b, record.SpanID)
+	}
+	b = protowire.AppendTag(b, logRecordObservedTimeUnixNano, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, uint64(record.ObservedTime.UnixNano()))
+}
+
+func appendKeyValue(b []byte, kv otlpKeyValue) []byte {
+	b = protowire.AppendTag(b, keyValueKey, protowire.BytesType)
+	b = protowire.AppendString(b, kv.Key)
+	return appendMessage(b, keyValueValue, appendAnyValue(nil, kv.Value))
+}
+
+func appendAnyValue(b []byte, value otlpValue) []byte {
+	switch v := value.(type) {
+	case string:
+		b = protowire.AppendTag(b, anyValueString, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	case bool:
+		b = protowire.AppendTag(b, anyValueBool, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case int64:
+		b = protowire.AppendTag(b, anyValueInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(v))
+	case float64:
+		b = protowire.AppendTag(b, anyValueDouble, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(v))
+	case []byte:
+		b = protowire.AppendTag(b, anyValueBytes, protowire.BytesType)
+		b = protowire.AppendBytes(b, v)
+	case []otlpValue:
+		var array []byte
+		for _, elem := range v {
+			array = appendMessage(array, arrayValueValues, appendAnyValue(nil, elem))
+		}
+		b = appendMessage(b, anyValueArray, array)
+	case []otlpKeyValue:
+		var list []byte
+		for _, kv := range v {
+			list = appendMessage(list, kvListValueValues, appendKeyValue(nil, kv))
+		}
+		b = appendMessage(b, anyValueKVList, list)
+	}
+	return b
+}
+
+// encodeOTLPJSON encodes records as an ExportLogsServiceRequest using the
+// OTLP/JSON mapping: 64-bit integers are strings, trace and span IDs are
+// hex strings and enums are numbers.
+func encodeOTLPJSON(resource []otlpKeyValue, records []otlpRecord) []byte {
+	logRecords := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		r := map[string]interface{}{
+			"timeUnixNano":         strconv.FormatInt(record.Time.UnixNano(), 10),
+			"observedTimeUnixNano": strconv.FormatInt(record.ObservedTime.UnixNano(), 10),
+			"severityNumber":       record.SeverityNumber,
+			"severityText":         record.SeverityText,
+			"body":                 jsonAnyValue(record.Body),
+			"attributes":           jsonKeyValues(record.Attributes),
+		}
+		if record.Flags != 0 {
+			r["flags"] = record.Flags
+		}
+		if len(record.TraceID) > 0 {
+			r["traceId"] = hex.EncodeToString(record.TraceID)
+		}
+		if len(record.SpanID) > 0 {
+			r["spanId"] = hex.EncodeToString(record.SpanID)
+		}
+		logRecords[i] = r
+	}
+
+	request := map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": jsonKeyValues(resource),
+				},
+				"scopeLogs": []interface{}{
+					map[string]interface{}{
+						"scope":      map[string]interface{}{"name": otlpScopeName},
+						"logRecords": logRecords,
+					},
+				},
+			},
+		},
+	}
+
+	// Only plain maps, slices and scalars are marshaled, which cannot fail
+	data, _ := json.Marshal(request)
+	return data
+}
+
+func jsonKeyValues(kvs []otlpKeyValue) []interface{} {
+	out := make([]interface{}, len(kvs))
+	for i, kv := range kvs {
+		out[i] = map[string]interface{}{"key": kv.Key, "value": jsonAnyValue(kv.Value)}
+	}
+	return out
+}
+
+func jsonAnyValue(value otlpValue) map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return map[string]interface{}{"stringValue": strconv.FormatFloat(v, 'g', -1, 64)}
+		}
+		return map[string]interface{}{"doubleValue": v}
+	case []byte:
+		return map[string]interface{}{"bytesValue": base64.StdEncoding.EncodeToString(v)}
+	case []otlpValue:
+		values := make([]interface{}, len(v))
+		for i, elem := range v {
+			values[i] = jsonAnyValue(elem)
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case []otlpKeyValue:
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": jsonKeyValues(v)}}
+	default:
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,310 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// collectorStub is a local stand-in for an OTLP/HTTP collector
+type collectorStub struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int // responses for successive requests, then 200
+}
+
+func newCollectorStub(t *testing.T, statuses ...int) (*collectorStub, string) {
+	stub := &collectorStub{statuses: statuses}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		stub.mu.Lock()
+		stub.requests = append(stub.requests, r)
+		stub.bodies = append(stub.bodies, body)
+		status := http.StatusOK
+		if len(stub.statuses) > 0 {
+			status, stub.statuses = stub.statuses[0], stub.statuses[1:]
+		}
+		stub.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return stub, srv.URL + "/v1/logs"
+}
+
+func (c *collectorStub) received() ([]*http.Request, [][]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.bodies
+}
+
+// testSpanContext returns a context with a fixed remote span
+func testSpanContext() context.Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+}
+
+func attributeMap(kvs []*commonpb.KeyValue) map[string]*commonpb.AnyValue {
+	m := make(map[string]*commonpb.AnyValue, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestOTLPProtobufExport(t *testing.T) {
+	stub, endpoint := newCollectorStub(t)
+	config := DefaultConfig(Staging)
+	config.ServiceName = "checkout"
+	config.OTLP = &OTLPConfig{
+		Endpoint:           endpoint,
+		Headers:            map[string]string{"Authorization": "Bearer token"},
+		ResourceAttributes: map[string]string{"host.name": "pod-1"},
+	}
+	initializeToFile(t, config)
+
+	WarnContext(testSpanContext(), "payment retried",
+		zap.String("order_id", "o-1"),
+		zap.Int("attempt", 2),
+		zap.Bool("card", true),
+		zap.Float64("amount", 9.5),
+		zap.Strings("tags", []string{"a", "b"}),
+	)
+	Notice("notice message")
+	Debug("filtered by level")
+	if err := Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	requests, bodies := stub.received()
+	if len(requests) != 1 {
+		t.Fatalf("collector received %d requests, want 1", len(requests))
+	}
+	if got := requests[0].Header.Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := requests[0].Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	var req collogspb.ExportLogsServiceRequest
+	if err := proto.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatalf("invalid protobuf request: %v", err)
+	}
+	resource := attributeMap(req.ResourceLogs[0].Resource.Attributes)
+	if resource["service.name"].GetStringValue() != "checkout" ||
+		resource["deployment.environment"].GetStringValue() != "staging" ||
+		resource["host.name"].GetStringValue() != "pod-1" {
+		t.Errorf("resource attributes = %v", resource)
+	}
+
+	scope := req.ResourceLogs[0].ScopeLogs[0]
+	if scope.Scope.Name != otlpScopeName {
+		t.Errorf("scope name = %q", scope.Scope.Name)
+	}
+	records := scope.LogRecords
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	record := records[0]
+	if record.SeverityNumber != 13 || record.SeverityText != "WARN" {
+		t.Errorf("severity = %v %q, want 13 WARN", record.SeverityNumber, record.SeverityText)
+	}
+	if record.Body.GetStringValue() != "payment retried" {
+		t.Errorf("body = %v", record.Body)
+	}
+	if record.TimeUnixNano == 0 || record.ObservedTimeUnixNano == 0 {
+		t.Error("record is missing timestamps")
+	}
+	sc := trace.SpanContextFromContext(testSpanContext())
+	if trace.TraceID(record.TraceId) != sc.TraceID() || trace.SpanID(record.SpanId) != sc.SpanID() || record.Flags != 1 {
+		t.Errorf("trace context = %x %x %d", record.TraceId, record.SpanId, record.Flags)
+	}
+
+	attrs := attributeMap(record.Attributes)
+	if attrs["order_id"].GetStringValue() != "o-1" ||
+		attrs["attempt"].GetIntValue() != 2 ||
+		!attrs["card"].GetBoolValue() ||
+		attrs["amount"].GetDoubleValue() != 9.5 ||
+		len(attrs["tags"].GetArrayValue().GetValues()) != 2 {
+		t.Errorf("attributes = %v", attrs)
+	}
+	if _, ok := attrs[TraceIDKey]; ok {
+		t.Error("trace_id was exported as an attribute")
+	}
+
+	if records[1].SeverityNumber != 10 || records[1].SeverityText != "NOTICE" {
+		t.Errorf("notice severity = %v %q, want 10 NOTICE", records[1].SeverityNumber, records[1].SeverityText)
+	}
+}
+
+func TestOTLPJSONExport(t *testing.T) {
+	stub, endpoint := newCollectorStub(t)
+	config := DefaultConfig(Production)
+	config.OTLP = &OTLPConfig{Endpoint: endpoint, Protocol: OTLPJSON}
+	initializeToFile(t, config)
+
+	ErrorContext(testSpanContext(), "query failed", zap.Int64("rows", 42))
+	Sync()
+
+	requests, bodies := stub.received()
+	if len(requests) != 1 {
+		t.Fatalf("collector received %d requests, want 1", len(requests))
+	}
+	if got := requests[0].Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	var req struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string                 `json:"key"`
+					Value map[string]interface{} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				LogRecords []struct {
+					TimeUnixNano   string `json:"timeUnixNano"`
+					SeverityNumber int    `json:"severityNumber"`
+					SeverityText   string `json:"severityText"`
+					Body           map[string]interface{}
+					Attributes     []struct {
+						Key   string                 `json:"key"`
+						Value map[string]interface{} `json:"value"`
+					} `json:"attributes"`
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+					Flags   int    `json:"flags"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatalf("invalid JSON request: %v", err)
+	}
+
+	if attr := req.ResourceLogs[0].Resource.Attributes[0]; attr.Key != "service.name" || attr.Value["stringValue"] != "unknown_service" {
+		t.Errorf("first resource attribute = %+v", attr)
+	}
+	record := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if record.SeverityNumber != 17 || record.SeverityText != "ERROR" || record.Body["stringValue"] != "query failed" {
+		t.Errorf("record = %+v", record)
+	}
+	if record.TimeUnixNano == "" || record.Flags != 1 {
+		t.Errorf("record = %+v", record)
+	}
+	if record.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || record.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("trace context = %s %s, want hex IDs", record.TraceID, record.SpanID)
+	}
+	found := false
+	for _, attr := range record.Attributes {
+		if attr.Key == "rows" {
+			found = attr.Value["intValue"] == "42"
+		}
+	}
+	if !found {
+		t.Errorf("rows attribute missing or not a string-encoded int: %+v", record.Attributes)
+	}
+}
+
+func TestOTLPBatching(t *testing.T) {
+	stub, endpoint := newCollectorStub(t)
+	config := DefaultConfig(Staging)
+	config.OTLP = &OTLPConfig{Endpoint: endpoint, BatchSize: 2, FlushInterval: time.Hour}
+	initializeToFile(t, config)
+
+	for i := 0; i < 5; i++ {
+		Info("batched", zap.Int("i", i))
+	}
+	Sync()
+
+	_, bodies := stub.received()
+	total := 0
+	for _, body := range bodies {
+		var req collogspb.ExportLogsServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Fatalf("invalid protobuf request: %v", err)
+		}
+		n := len(req.ResourceLogs[0].ScopeLogs[0].LogRecords)
+		if n > 2 {
+			t.Errorf("batch has %d records, want at most 2", n)
+		}
+		total += n
+	}
+	if total != 5 {
+		t.Errorf("exported %d records, want 5", total)
+	}
+}
+
+func TestOTLPRetry(t *testing.T) {
+	stub, endpoint := newCollectorStub(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	config := DefaultConfig(Staging)
+	config.OTLP = &OTLPConfig{Endpoint: endpoint, RetryBackoff: time.Millisecond}
+	initializeToFile(t, config)
+
+	before := InternalErrors()
+	Info("retried")
+	if err := Sync(); err != nil {
+		t.Errorf("Sync() error = %v, want nil after successful retry", err)
+	}
+
+	if requests, _ := stub.received(); len(requests) != 3 {
+		t.Errorf("collector received %d requests, want 3", len(requests))
+	}
+	if InternalErrors() != before {
+		t.Error("a successful retry was reported as an internal error")
+	}
+}
+
+func TestOTLPPermanentFailure(t *testing.T) {
+	stub, endpoint := newCollectorStub(t, http.StatusBadRequest)
+	config := DefaultConfig(Staging)
+	config.ErrorOutputPaths = []string{t.TempDir() + "/errors.log"}
+	config.OTLP = &OTLPConfig{Endpoint: endpoint, RetryBackoff: time.Millisecond}
+	initializeToFile(t, config)
+
+	before := InternalErrors()
+	Info("rejected")
+	if err := Sync(); err == nil {
+		t.Error("Sync() error = nil, want export error")
+	}
+
+	if requests, _ := stub.received(); len(requests) != 1 {
+		t.Errorf("collector received %d requests, want 1 (no retry on 400)", len(requests))
+	}
+	if InternalErrors() == before {
+		t.Error("export failure was not reported as an internal error")
+	}
+}
+
+func TestOTLPConfigValidation(t *testing.T) {
+	defer Initialize(DefaultConfig(Test))
+
+	config := DefaultConfig(Test)
+	config.OTLP = &OTLPConfig{}
+	if err := Initialize(config); err == nil {
+		t.Error("Initialize() with an empty endpoint error = nil")
+	}
+	config.OTLP = &OTLPConfig{Endpoint: "http://localhost:4318/v1/logs", Protocol: "grpc"}
+	if err := Initialize(config); err == nil {
+		t.Error("Initialize() with an unsupported protocol error = nil")
+	}
+}
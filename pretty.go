@@ -0,0 +1,408 @@
+package logger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultPrettyWidth is the default PrettyConfig.Width
+	DefaultPrettyWidth = 100
+	// DefaultPrettyTimeLayout is the default PrettyConfig.TimeLayout
+	DefaultPrettyTimeLayout = "15:04:05.000"
+)
+
+// ColorMode selects whether the pretty encoding uses colors
+type ColorMode int
+
+const (
+	// ColorAuto uses colors if every output is a terminal and the NO_COLOR
+	// environment variable is not set
+	ColorAuto ColorMode = iota
+	// ColorAlways always uses colors
+	ColorAlways
+	// ColorNever never uses colors
+	ColorNever
+)
+
+// PrettyConfig configures the "pretty" encoding, a human-friendly layout
+// for development. Each entry starts with a line holding the time, a level
+// badge, the logger name, the message and the caller, right-aligned. The
+// fields follow, one per line and with objects and arrays pretty-printed,
+// and then stack traces, set apart from the fields.
+type PrettyConfig struct {
+	// Color selects colored level badges, keys and error sections
+	Color ColorMode
+	// RelativeTime shows the time since Initialize, e.g. "+   1.234s",
+	// instead of the wall clock time
+	RelativeTime bool
+	// TimeLayout formats the wall clock time. Defaults to
+	// DefaultPrettyTimeLayout.
+	TimeLayout string
+	// FieldOrder lists keys that are shown first, in this order. Other
+	// fields follow in the order they were added.
+	FieldOrder []string
+	// Width is the column the caller is right-aligned to. Defaults to
+	// DefaultPrettyWidth.
+	Width int
+}
+
+// ANSI escape sequences used by the pretty encoding
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiRed   = "\x1b[31m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// prettyBadges are the level badges, with the colors used for them
+var prettyBadges = map[zapcore.Level]struct{ text, color string }{
+	TraceLevel:          {"TRC", "\x1b[97;100m"},
+	zapcore.DebugLevel:  {"DBG", "\x1b[30;46m"},
+	zapcore.InfoLevel:   {"INF", "\x1b[30;42m"},
+	NoticeLevel:         {"NTC", "\x1b[97;44m"},
+	zapcore.WarnLevel:   {"WRN", "\x1b[30;43m"},
+	zapcore.ErrorLevel:  {"ERR", "\x1b[97;41m"},
+	CriticalLevel:       {"CRT", "\x1b[1;97;41m"},
+	zapcore.DPanicLevel: {"DPN", "\x1b[1;97;45m"},
+	zapcore.PanicLevel:  {"PNC", "\x1b[1;97;45m"},
+	zapcore.FatalLevel:  {"FTL", "\x1b[1;97;45m"},
+}
+
+var prettyPool = buffer.NewPool()
+
+// prettyIndent indents fields under the entry line
+const prettyIndent = "    "
+
+// prettyEncoder implements the "pretty" encoding
+type prettyEncoder struct {
+	*prettyFields
+	config PrettyConfig
+	color  bool
+	start  time.Time
+	// caller selects how the caller is shown
+	caller CallerMode
+}
+
+// newPrettyEncoder returns a pretty encoder. Relative times are measured
+// from start.
+func newPrettyEncoder(config PrettyConfig, color bool, start time.Time) *prettyEncoder {
+	if config.Width <= 0 {
+		config.Width = DefaultPrettyWidth
+	}
+	if config.TimeLayout == "" {
+		config.TimeLayout = DefaultPrettyTimeLayout
+	}
+	return &prettyEncoder{prettyFields: &prettyFields{}, config: config, color: color, start: start}
+}
+
+// NewPrettyEncoder returns an encoder with the "pretty" layout, e.g. to
+// render entries read back from JSON logs. With ColorAuto, colors are used
+// if stdout is a terminal.
+func NewPrettyEncoder(config PrettyConfig) zapcore.Encoder {
+	return newPrettyEncoder(config, prettyColor(config.Color, []string{"stdout"}), time.Now())
+}
+
+// prettyColor reports whether the pretty encoding should use colors for
+// output paths
+func prettyColor(mode ColorMode, paths []string) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || len(paths) == 0 {
+		return false
+	}
+	for _, path := range paths {
+		var f *os.File
+		switch path {
+		case "stdout":
+			f = os.Stdout
+		case "stderr":
+			f = os.Stderr
+		default:
+			return false
+		}
+		if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone implements zapcore.Encoder
+func (e *prettyEncoder) Clone() zapcore.Encoder {
+	clone := *e
+	clone.prettyFields = e.prettyFields.clone()
+	return &clone
+}
+
+// EncodeEntry implements zapcore.Encoder
+func (e *prettyEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	all := e.prettyFields.clone()
+	for _, f := range fields {
+		f.AddTo(all)
+	}
+
+	buf := prettyPool.Get()
+	e.writeHeader(buf, ent)
+
+	var sections []prettyField
+	for _, f := range e.order(all.fields) {
+		if s, ok := f.value.(string); ok && isStackKey(f.key) && strings.Contains(s, "\n") {
+			sections = append(sections, f)
+			continue
+		}
+		e.writeField(buf, f)
+	}
+	for _, f := range sections {
+		e.writeSection(buf, f.key, f.value.(string))
+	}
+	if ent.Stack != "" {
+		e.writeSection(buf, "stacktrace", ent.Stack)
+	}
+	return buf, nil
+}
+
+// writeHeader writes the first line of an entry
+func (e *prettyEncoder) writeHeader(buf *buffer.Buffer, ent zapcore.Entry) {
+	visible := 0
+	write := func(s, color string) {
+		visible += utf8.RuneCountInString(s)
+		e.colored(buf, s, color)
+	}
+
+	if e.config.RelativeTime {
+		write(fmt.Sprintf("+%8.3fs", ent.Time.Sub(e.start).Seconds()), ansiDim)
+	} else {
+		write(ent.Time.Format(e.config.TimeLayout), ansiDim)
+	}
+	write(" ", "")
+	badge, ok := prettyBadges[ent.Level]
+	if !ok {
+		badge.text = strings.ToUpper(LevelName(ent.Level))
+	}
+	write(" "+badge.text+" ", badge.color)
+	write(" ", "")
+	if ent.LoggerName != "" {
+		write("["+ent.LoggerName+"] ", ansiCyan)
+	}
+	write(ent.Message, ansiBold)
+
+	if ent.Caller.Defined {
+		caller := e.caller.format(ent.Caller)
+		pad := e.config.Width - visible - utf8.RuneCountInString(caller)
+		if pad < 2 {
+			pad = 2
+		}
+		buf.AppendString(strings.Repeat(" ", pad))
+		e.colored(buf, caller, ansiDim)
+	}
+	buf.AppendByte('\n')
+}
+
+// writeField writes a field on its own line, continuing multi-line values
+// at the field's indentation
+func (e *prettyEncoder) writeField(buf *buffer.Buffer, f prettyField) {
+	buf.AppendString(prettyIndent)
+	keyColor, valueColor := ansiCyan, ""
+	if isErrorKey(f.key) {
+		keyColor, valueColor = ansiRed+ansiBold, ansiRed
+	}
+	e.colored(buf, f.key+":", keyColor)
+	buf.AppendByte(' ')
+	value := formatPrettyValue(f.value)
+	value = strings.ReplaceAll(value, "\n", "\n"+prettyIndent)
+	e.colored(buf, value, valueColor)
+	buf.AppendByte('\n')
+}
+
+// writeSection writes a stack trace below the fields
+func (e *prettyEncoder) writeSection(buf *buffer.Buffer, key, text string) {
+	buf.AppendString(prettyIndent)
+	e.colored(buf, key+":", ansiRed+ansiBold)
+	buf.AppendByte('\n')
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		buf.AppendString(prettyIndent + "  ")
+		e.colored(buf, line, ansiDim)
+		buf.AppendByte('\n')
+	}
+}
+
+// colored appends s, wrapped in color if colors are enabled
+func (e *prettyEncoder) colored(buf *buffer.Buffer, s, color string) {
+	if !e.color || color == "" {
+		buf.AppendString(s)
+		return
+	}
+	buf.AppendString(color)
+	buf.AppendString(s)
+	buf.AppendString(ansiReset)
+}
+
+// order returns fields with those in FieldOrder first
+func (e *prettyEncoder) order(fields []prettyField) []prettyField {
+	if len(e.config.FieldOrder) == 0 {
+		return fields
+	}
+	ordered := make([]prettyField, 0, len(fields))
+	used := make([]bool, len(fields))
+	for _, key := range e.config.FieldOrder {
+		for i, f := range fields {
+			if !used[i] && f.key == key {
+				ordered = append(ordered, f)
+				used[i] = true
+			}
+		}
+	}
+	for i, f := range fields {
+		if !used[i] {
+			ordered = append(ordered, f)
+		}
+	}
+	return ordered
+}
+
+// isErrorKey reports whether key holds an error, e.g. "error" or
+// "cause_chain" from NamedErr
+func isErrorKey(key string) bool {
+	lower := strings.ToLower(key)
+	return strings.HasSuffix(lower, "error") || strings.HasSuffix(lower, "_chain")
+}
+
+// isStackKey reports whether key holds a stack trace, such as the
+// errorVerbose of zap.Error or the _stack of Err
+func isStackKey(key string) bool {
+	return key == "errorVerbose" || strings.HasSuffix(key, "_stack") || key == "stacktrace"
+}
+
+// formatPrettyValue formats a field value. Strings are quoted only if they
+// are empty or hold characters that could be confused with the layout.
+func formatPrettyValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" || !isPlain(v) {
+			return strconv.Quote(v)
+		}
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("%+v", v)
+		}
+		return string(data)
+	case fmt.Stringer:
+		return v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128:
+		return fmt.Sprint(v)
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("%+v", v)
+		}
+		return string(data)
+	}
+}
+
+// isPlain reports whether s can be shown without quotes
+func isPlain(s string) bool {
+	if strings.TrimSpace(s) != s {
+		return false
+	}
+	for _, r := range s {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// prettyField is a field collected by prettyFields
+type prettyField struct {
+	key   string
+	value interface{}
+}
+
+// prettyFields collects fields in the order they are added. Objects and
+// arrays are converted with a zapcore.MapObjectEncoder.
+type prettyFields struct {
+	fields []prettyField
+	// prefix holds the namespaces opened with OpenNamespace
+	prefix string
+}
+
+func (p *prettyFields) clone() *prettyFields {
+	return &prettyFields{fields: append([]prettyField(nil), p.fields...), prefix: p.prefix}
+}
+
+func (p *prettyFields) add(key string, value interface{}) {
+	p.fields = append(p.fields, prettyField{p.prefix + key, value})
+}
+
+func (p *prettyFields) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := m.AddArray(key, arr)
+	p.add(key, m.Fields[key])
+	return err
+}
+
+func (p *prettyFields) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := m.AddObject(key, obj)
+	p.add(key, m.Fields[key])
+	return err
+}
+
+func (p *prettyFields) AddBinary(key string, value []byte)          { p.add(key, value) }
+func (p *prettyFields) AddByteString(key string, value []byte)      { p.add(key, string(value)) }
+func (p *prettyFields) AddBool(key string, value bool)              { p.add(key, value) }
+func (p *prettyFields) AddComplex128(key string, value complex128)  { p.add(key, value) }
+func (p *prettyFields) AddComplex64(key string, value complex64)    { p.add(key, value) }
+func (p *prettyFields) AddDuration(key string, value time.Duration) { p.add(key, value) }
+func (p *prettyFields) AddFloat64(key string, value float64)        { p.add(key, value) }
+func (p *prettyFields) AddFloat32(key string, value float32)        { p.add(key, value) }
+func (p *prettyFields) AddInt(key string, value int)                { p.add(key, value) }
+func (p *prettyFields) AddInt64(key string, value int64)            { p.add(key, value) }
+func (p *prettyFields) AddInt32(key string, value int32)            { p.add(key, value) }
+func (p *prettyFields) AddInt16(key string, value int16)            { p.add(key, value) }
+func (p *prettyFields) AddInt8(key string, value int8)              { p.add(key, value) }
+func (p *prettyFields) AddString(key, value string)                 { p.add(key, value) }
+func (p *prettyFields) AddTime(key string, value time.Time)         { p.add(key, value) }
+func (p *prettyFields) AddUint(key string, value uint)              { p.add(key, value) }
+func (p *prettyFields) AddUint64(key string, value uint64)          { p.add(key, value) }
+func (p *prettyFields) AddUint32(key string, value uint32)          { p.add(key, value) }
+func (p *prettyFields) AddUint16(key string, value uint16)          { p.add(key, value) }
+func (p *prettyFields) AddUint8(key string, value uint8)            { p.add(key, value) }
+func (p *prettyFields) AddUintptr(key string, value uintptr)        { p.add(key, value) }
+
+func (p *prettyFields) AddReflected(key string, value interface{}) error {
+	if fn, ok := value.(lazyValue); ok {
+		value = fn.value()
+	}
+	p.add(key, value)
+	return nil
+}
+
+func (p *prettyFields) OpenNamespace(key string) {
+	p.prefix += key + "."
+}
@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// prettyLines logs through a pretty encoder and returns the output lines
+func prettyLines(t *testing.T, pretty PrettyConfig, log func()) []string {
+	t.Helper()
+	config := DefaultConfig(Development)
+	config.Encoding = "pretty"
+	config.Pretty = &pretty
+	read := initializeToFile(t, config)
+	log()
+	return read()
+}
+
+func TestPrettyLayout(t *testing.T) {
+	lines := prettyLines(t, PrettyConfig{Color: ColorNever, Width: 80, FieldOrder: []string{"request_id"}}, func() {
+		GetLogger().Named("api").Info("user logged in",
+			zap.Int("user_id", 42),
+			zap.String("name", "Alice Smith"),
+			zap.String("note", "two\nlines"),
+			zap.Strings("roles", []string{"admin"}),
+			zap.String("request_id", "r-1"),
+		)
+	})
+
+	want := []string{
+		`^\d\d:\d\d:\d\d\.\d{3}  INF  \[api\] user logged in +\S+/pretty_test\.go:\d+$`,
+		`^    request_id: r-1$`,
+		`^    user_id: 42$`,
+		`^    name: Alice Smith$`,
+		`^    note: "two\\nlines"$`,
+		`^    roles: \[$`,
+		`^      "admin"$`,
+		`^    \]$`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+	}
+	for i, pattern := range want {
+		if !regexp.MustCompile(pattern).MatchString(lines[i]) {
+			t.Errorf("line %d = %q, want it to match %s", i, lines[i], pattern)
+		}
+	}
+}
+
+func TestPrettyCallerAlignment(t *testing.T) {
+	enc := NewPrettyEncoder(PrettyConfig{Color: ColorNever, Width: 60, TimeLayout: time.TimeOnly})
+	for _, tt := range []struct {
+		msg  string
+		want string
+	}{
+		{"started", "10:30:00  INF  started                     api/handler.go:42\n"},
+		{"started serving requests on port 8080", "10:30:00  INF  started serving requests on port 8080  api/handler.go:42\n"},
+	} {
+		buf, err := enc.EncodeEntry(zapcore.Entry{
+			Level:   zapcore.InfoLevel,
+			Time:    time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+			Message: tt.msg,
+			Caller:  zapcore.NewEntryCaller(0, "/src/app/api/handler.go", 42, true),
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("EncodeEntry() = %q, want %q", buf.String(), tt.want)
+		}
+	}
+}
+
+func TestPrettyErrorSections(t *testing.T) {
+	lines := prettyLines(t, PrettyConfig{Color: ColorNever}, func() {
+		Error("payment failed", Err(fmt.Errorf("charge: %w", newStackError("card declined"))))
+	})
+	output := strings.Join(lines, "\n")
+
+	for _, want := range []string{
+		"\n    error: charge: card declined\n",
+		"\n    error_chain: [\n",
+		"\n    error_stack:\n      github.com/kingrain94/logger.newStackError\n",
+		"\n    stacktrace:\n      github.com/kingrain94/logger.",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+	// Stack traces follow the other fields
+	if strings.Index(output, "error_stack:") < strings.Index(output, "error_chain:") {
+		t.Errorf("error_stack is shown before error_chain:\n%s", output)
+	}
+}
+
+func TestPrettyColor(t *testing.T) {
+	lines := prettyLines(t, PrettyConfig{Color: ColorAlways}, func() {
+		Warn("disk almost full", zap.Error(errors.New("98% used")))
+	})
+	output := strings.Join(lines, "\n")
+	for _, want := range []string{"\x1b[30;43m WRN \x1b[0m", "\x1b[1mdisk almost full\x1b[0m", ansiRed + ansiBold + "error:"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q: %q", want, output)
+		}
+	}
+
+	if prettyColor(ColorAuto, []string{"/var/log/app.log"}) {
+		t.Error("colors enabled for a file output")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if prettyColor(ColorAuto, []string{"stdout"}) {
+		t.Error("colors enabled with NO_COLOR set")
+	}
+	if !prettyColor(ColorAlways, []string{"/var/log/app.log"}) {
+		t.Error("colors disabled with ColorAlways")
+	}
+}
+
+func TestPrettyRelativeTime(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	enc := newPrettyEncoder(PrettyConfig{RelativeTime: true}, false, start)
+	enc.AddString("tenant", "acme")
+
+	buf, err := enc.Clone().EncodeEntry(zapcore.Entry{
+		Level:   NoticeLevel,
+		Time:    start.Add(1234 * time.Millisecond),
+		Message: "started",
+	}, []zapcore.Field{zap.Duration("took", time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "+   1.234s  NTC  started\n    tenant: acme\n    took: 1s\n"
+	if buf.String() != want {
+		t.Errorf("EncodeEntry() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPrettyTimeLayout(t *testing.T) {
+	enc := NewPrettyEncoder(PrettyConfig{Color: ColorNever, TimeLayout: time.DateTime})
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:   zapcore.InfoLevel,
+		Time:    time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Message: "started",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-15 10:30:00  INF  started\n"; buf.String() != want {
+		t.Errorf("EncodeEntry() = %q, want %q", buf.String(), want)
+	}
+}
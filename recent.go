@@ -0,0 +1,306 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kingrain94/logger/internal/filter"
+	"go.uber.org/zap/zapcore"
+)
+
+// recentEntry is an entry kept in the recent buffer, with its fields
+// encoded at the time it was logged
+type recentEntry struct {
+	seq    uint64
+	Time   time.Time              `json:"time"`
+	Level  string                 `json:"level"`
+	Logger string                 `json:"logger,omitempty"`
+	Caller string                 `json:"caller,omitempty"`
+	Msg    string                 `json:"msg"`
+	Stack  string                 `json:"stacktrace,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	level zapcore.Level
+}
+
+// recentBuffer is a lock-free ring buffer of the most recent entries.
+// Writers claim a slot with an atomic counter; readers take a snapshot of
+// the slots and order it by sequence number.
+type recentBuffer struct {
+	next  atomic.Uint64
+	slots []atomic.Pointer[recentEntry]
+}
+
+func newRecentBuffer(size int) *recentBuffer {
+	return &recentBuffer{slots: make([]atomic.Pointer[recentEntry], size)}
+}
+
+// add stores e, overwriting the oldest entry once the buffer is full
+func (b *recentBuffer) add(e *recentEntry) {
+	e.seq = b.next.Add(1) - 1
+	slot := &b.slots[e.seq%uint64(len(b.slots))]
+	// A slow writer must not overwrite a newer entry in the same slot
+	for {
+		old := slot.Load()
+		if old != nil && old.seq > e.seq {
+			return
+		}
+		if slot.CompareAndSwap(old, e) {
+			return
+		}
+	}
+}
+
+// snapshot returns the buffered entries, oldest first
+func (b *recentBuffer) snapshot() []*recentEntry {
+	entries := make([]*recentEntry, 0, len(b.slots))
+	for i := range b.slots {
+		if e := b.slots[i].Load(); e != nil {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	return entries
+}
+
+// recentCore records every entry in a recentBuffer, regardless of level,
+// and passes entries to the wrapped, level-filtered core as usual
+type recentCore struct {
+	zapcore.Core
+	buffer *recentBuffer
+	fields []zapcore.Field
+}
+
+func newRecentCore(core zapcore.Core, buffer *recentBuffer) zapcore.Core {
+	return &recentCore{Core: core, buffer: buffer}
+}
+
+// outputCore returns the part of core that writes to the outputs, without
+// the recent buffer. The limits, which wrap the recent buffer, still apply.
+func outputCore(core zapcore.Core) zapcore.Core {
+	switch c := core.(type) {
+	case *recentCore:
+		return c.Core
+	case *limitCore:
+		if inner := outputCore(c.Core); inner != c.Core {
+			clone := *c
+			clone.Core = inner
+			return &clone
+		}
+	}
+	return core
+}
+
+// Enabled implements zapcore.Core
+func (c *recentCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+// With implements zapcore.Core
+func (c *recentCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &recentCore{Core: c.Core.With(fields), buffer: c.buffer}
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return clone
+}
+
+// Check implements zapcore.Core. The wrapped core adds itself if it
+// enables the entry.
+func (c *recentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	ce = c.Core.Check(ent, ce)
+	return ce.AddCore(ent, recentRecorder{c})
+}
+
+// recentRecorder is added to checked entries by recentCore so that writing
+// an entry records it without writing it to the wrapped core again
+type recentRecorder struct {
+	*recentCore
+}
+
+// Write implements zapcore.Core
+func (r recentRecorder) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range r.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	// Lazy values are computed now, while the logging goroutine owns what
+	// they refer to
+	resolveLazy(enc.Fields)
+
+	e := &recentEntry{
+		Time:   ent.Time,
+		Level:  LevelName(ent.Level),
+		Logger: ent.LoggerName,
+		Msg:    ent.Message,
+		Stack:  ent.Stack,
+		Fields: enc.Fields,
+		level:  ent.Level,
+	}
+	if ent.Caller.Defined {
+		e.Caller = ent.Caller.TrimmedPath()
+	}
+	r.buffer.add(e)
+	return nil
+}
+
+// RecentHandler serves the entries kept by Config.RecentBuffer, oldest
+// first. It responds 404 if the recent buffer is disabled. Query parameters:
+//
+//   - level: minimum level, e.g. "warn"
+//   - field: "key=value" to match a field's value or "key" to require the
+//     field; may be repeated
+//   - since, until: RFC 3339 times, or durations such as "5m" meaning that
+//     long ago
+//   - limit: return only the most recent entries
+//   - format: "json" (default) or "text"
+func RecentHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		buffer := recent
+		mu.RUnlock()
+		if buffer == nil {
+			http.Error(w, "recent buffer is not enabled", http.StatusNotFound)
+			return
+		}
+
+		filter, err := parseRecentFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entries := buffer.snapshot()
+		matched := entries[:0]
+		for _, e := range entries {
+			if filter.match(e) {
+				matched = append(matched, e)
+			}
+		}
+		if filter.limit > 0 && len(matched) > filter.limit {
+			matched = matched[len(matched)-filter.limit:]
+		}
+
+		switch filter.format {
+		case "text":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for _, e := range matched {
+				writeRecentText(w, e)
+			}
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(matched)
+		}
+	})
+}
+
+// recentFilter selects entries served by RecentHandler
+type recentFilter struct {
+	level  *zapcore.Level
+	fields map[string]*string
+	since  time.Time
+	until  time.Time
+	limit  int
+	format string
+}
+
+func parseRecentFilter(r *http.Request) (*recentFilter, error) {
+	q := r.URL.Query()
+	f := &recentFilter{fields: map[string]*string{}, format: "json"}
+
+	if v := q.Get("level"); v != "" {
+		level, err := ParseLevel(v)
+		if err != nil {
+			return nil, err
+		}
+		f.level = &level
+	}
+	for _, v := range q["field"] {
+		if key, value, ok := strings.Cut(v, "="); ok {
+			f.fields[key] = &value
+		} else {
+			f.fields[v] = nil
+		}
+	}
+
+	now := time.Now()
+	var err error
+	if f.since, err = filter.ParseTime(q.Get("since"), now); err != nil {
+		return nil, fmt.Errorf("invalid since: %w", err)
+	}
+	if f.until, err = filter.ParseTime(q.Get("until"), now); err != nil {
+		return nil, fmt.Errorf("invalid until: %w", err)
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if f.limit, err = strconv.Atoi(v); err != nil || f.limit < 0 {
+			return nil, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	switch v := q.Get("format"); v {
+	case "":
+	case "json", "text":
+		f.format = v
+	default:
+		return nil, fmt.Errorf("invalid format %q", v)
+	}
+	return f, nil
+}
+
+func (f *recentFilter) match(e *recentEntry) bool {
+	if f.level != nil && !levelAtLeast(e.level, *f.level) {
+		return false
+	}
+	if !f.since.IsZero() && e.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && e.Time.After(f.until) {
+		return false
+	}
+	for key, want := range f.fields {
+		value, ok := e.Fields[key]
+		if !ok || (want != nil && fmt.Sprint(value) != *want) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeRecentText writes an entry in a format similar to the console
+// encoder: time, level, caller, message and the fields as JSON
+func writeRecentText(w http.ResponseWriter, e *recentEntry) {
+	var b strings.Builder
+	b.WriteString(e.Time.Format(time.RFC3339Nano))
+	b.WriteByte('\t')
+	b.WriteString(strings.ToUpper(e.Level))
+	if e.Logger != "" {
+		b.WriteByte('\t')
+		b.WriteString(e.Logger)
+	}
+	if e.Caller != "" {
+		b.WriteByte('\t')
+		b.WriteString(e.Caller)
+	}
+	b.WriteByte('\t')
+	b.WriteString(e.Msg)
+	if len(e.Fields) > 0 {
+		if data, err := json.Marshal(e.Fields); err == nil {
+			b.WriteByte('\t')
+			b.Write(data)
+		}
+	}
+	b.WriteByte('\n')
+	if e.Stack != "" {
+		b.WriteString(e.Stack)
+		b.WriteByte('\n')
+	}
+	_, _ = w.Write([]byte(b.String()))
+}
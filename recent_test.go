@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// getRecent requests RecentHandler with the given query
+func getRecent(t *testing.T, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	RecentHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/logs?"+query, nil))
+	return rec
+}
+
+// getRecentJSON requests RecentHandler and decodes the JSON response
+func getRecentJSON(t *testing.T, query string) []map[string]interface{} {
+	t.Helper()
+	rec := getRecent(t, query)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return entries
+}
+
+func TestRecentBufferAllLevels(t *testing.T) {
+	config := DefaultConfig(Production)
+	config.RecentBuffer = 3
+	read := initializeToFile(t, config)
+
+	Trace("zero")
+	Debug("one")
+	With(zap.String("component", "db")).Info("two", zap.Int("n", 2))
+	Warn("three")
+	Error("four")
+
+	if lines := read(); len(lines) != 2 {
+		t.Errorf("got %d output lines, want 2", len(lines))
+	}
+
+	entries := getRecentJSON(t, "")
+	want := []string{"two", "three", "four"}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %v", len(entries), len(want), entries)
+	}
+	for i, msg := range want {
+		if entries[i]["msg"] != msg {
+			t.Errorf("entry %d msg = %v, want %v", i, entries[i]["msg"], msg)
+		}
+	}
+	fields, _ := entries[0]["fields"].(map[string]interface{})
+	if entries[0]["level"] != "info" || fields["component"] != "db" || fields["n"] != float64(2) {
+		t.Errorf("entry = %v", entries[0])
+	}
+}
+
+func TestRecentHandlerFilters(t *testing.T) {
+	config := DefaultConfig(Production)
+	config.RecentBuffer = 10
+	initializeToFile(t, config)
+
+	Debug("debug", zap.String("user", "a"))
+	Info("info", zap.String("user", "b"))
+	Warn("warn", zap.String("user", "a"))
+	Error("error")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"level=warn", []string{"warn", "error"}},
+		{"field=user=a", []string{"debug", "warn"}},
+		{"field=user", []string{"debug", "info", "warn"}},
+		{"field=user&level=info", []string{"info", "warn"}},
+		{"limit=2", []string{"warn", "error"}},
+		{"since=1h", []string{"debug", "info", "warn", "error"}},
+		{"until=2000-01-01T00:00:00Z", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			entries := getRecentJSON(t, tt.query)
+			var got []string
+			for _, e := range entries {
+				got = append(got, e["msg"].(string))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"level=loud", "since=yesterday", "limit=-1", "format=xml"} {
+		if rec := getRecent(t, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec := getRecent(t, "format=text&level=error")
+	if body := rec.Body.String(); !strings.Contains(body, "\tERROR\t") || !strings.Contains(body, "\terror\n") {
+		t.Errorf("text body = %q", body)
+	}
+}
+
+func TestRecentBufferLazy(t *testing.T) {
+	config := DefaultConfig(Production)
+	config.RecentBuffer = 10
+	initializeToFile(t, config)
+
+	user := "a"
+	Debug("lazy", Lazy("user", func() interface{} { return user }))
+	user = "b"
+
+	entries := getRecentJSON(t, "field=user=a")
+	if len(entries) != 1 || entries[0]["msg"] != "lazy" {
+		t.Errorf("entries = %v, want the entry with the value when logged", entries)
+	}
+}
+
+func TestRecentHandlerDisabled(t *testing.T) {
+	initializeToFile(t, DefaultConfig(Production))
+
+	if rec := getRecent(t, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRecentBufferWithScope(t *testing.T) {
+	config := DefaultConfig(Production)
+	config.RecentBuffer = 10
+	read := initializeToFile(t, config)
+
+	ctx, scope := NewScope(context.Background())
+	DebugContext(ctx, "buffered")
+	ErrorContext(ctx, "failed")
+	scope.End()
+
+	if entries := decodeEntries(t, read()); len(entries) != 2 || entries[0]["msg"] != "buffered" {
+		t.Errorf("output entries = %v, want the buffered entry and the error", entries)
+	}
+	if entries := getRecentJSON(t, ""); len(entries) != 2 {
+		t.Errorf("got %d recent entries, want 2", len(entries))
+	}
+}
+
+func TestRecentBufferConcurrent(t *testing.T) {
+	buffer := newRecentBuffer(16)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				buffer.add(&recentEntry{})
+				buffer.snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := buffer.snapshot()
+	if len(entries) != 16 {
+		t.Fatalf("got %d entries, want 16", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].seq <= entries[i-1].seq {
+			t.Errorf("entries are not ordered: %d after %d", entries[i].seq, entries[i-1].seq)
+		}
+	}
+}
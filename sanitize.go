@@ -0,0 +1,175 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultMaxMessageLength is the default SanitizeConfig.MaxMessageLength
+	DefaultMaxMessageLength = 8192
+	// DefaultMaxFieldLength is the default SanitizeConfig.MaxFieldLength
+	DefaultMaxFieldLength = 4096
+)
+
+// SanitizeConfig protects outputs against log injection through user
+// controlled messages and string fields. With the console encoding, control
+// characters, including CR, LF and the ESC of terminal escape sequences, and
+// Unicode bidirectional overrides are escaped, so that input cannot start a
+// forged line or restyle the terminal; the JSON encoding already escapes
+// them. Messages and string fields longer than their maximum are truncated
+// with a marker giving the size removed, as with Limits.
+type SanitizeConfig struct {
+	// MaxMessageLength caps messages, in bytes before escaping. Defaults to
+	// DefaultMaxMessageLength; negative means no limit.
+	MaxMessageLength int
+	// MaxFieldLength caps string fields, in bytes before escaping. Defaults
+	// to DefaultMaxFieldLength; negative means no limit.
+	MaxFieldLength int
+}
+
+// withDefaults returns c with zero values replaced by defaults
+func (c SanitizeConfig) withDefaults() SanitizeConfig {
+	if c.MaxMessageLength == 0 {
+		c.MaxMessageLength = DefaultMaxMessageLength
+	}
+	if c.MaxFieldLength == 0 {
+		c.MaxFieldLength = DefaultMaxFieldLength
+	}
+	return c
+}
+
+// sanitizingEncoder sanitizes the message and string fields of entries
+// before they are encoded
+type sanitizingEncoder struct {
+	zapcore.Encoder
+	config SanitizeConfig
+	escape bool
+}
+
+// newSanitizingEncoder wraps enc. escape enables escaping of control
+// characters, for encodings that write strings verbatim.
+func newSanitizingEncoder(enc zapcore.Encoder, config SanitizeConfig, escape bool) zapcore.Encoder {
+	return sanitizingEncoder{Encoder: enc, config: config.withDefaults(), escape: escape}
+}
+
+// Clone implements zapcore.Encoder
+func (e sanitizingEncoder) Clone() zapcore.Encoder {
+	return sanitizingEncoder{Encoder: e.Encoder.Clone(), config: e.config, escape: e.escape}
+}
+
+// AddString implements zapcore.ObjectEncoder for fields added with With
+func (e sanitizingEncoder) AddString(key, value string) {
+	e.Encoder.AddString(key, e.sanitizeField(value))
+}
+
+// AddByteString implements zapcore.ObjectEncoder for fields added with With
+func (e sanitizingEncoder) AddByteString(key string, value []byte) {
+	e.Encoder.AddString(key, e.sanitizeField(string(value)))
+}
+
+// EncodeEntry implements zapcore.Encoder
+func (e sanitizingEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ent.Message = truncate(ent.Message, e.config.MaxMessageLength)
+	if e.escape {
+		ent.Message = escapeControl(ent.Message, false)
+	}
+
+	var sanitized []zapcore.Field
+	for i, f := range fields {
+		var value string
+		switch f.Type {
+		case zapcore.StringType:
+			value = f.String
+		case zapcore.ByteStringType:
+			value = string(f.Interface.([]byte))
+		default:
+			continue
+		}
+		if clean := e.sanitizeField(value); clean != value || f.Type != zapcore.StringType {
+			if sanitized == nil {
+				sanitized = append([]zapcore.Field(nil), fields...)
+			}
+			sanitized[i] = zap.String(f.Key, clean)
+		}
+	}
+	if sanitized != nil {
+		fields = sanitized
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+// sanitizeField truncates a string field and escapes it if enabled. The
+// console encoding writes fields as JSON, so only the characters that JSON
+// leaves unescaped need escaping.
+func (e sanitizingEncoder) sanitizeField(s string) string {
+	s = truncate(s, e.config.MaxFieldLength)
+	if e.escape {
+		s = escapeControl(s, true)
+	}
+	return s
+}
+
+// escapeControl escapes CR, LF, tab and other C0 and C1 control characters,
+// DEL, bidirectional overrides and invalid UTF-8 with Go escape sequences.
+// If jsonEscaped, characters that a JSON encoder escapes are left as is.
+func escapeControl(s string, jsonEscaped bool) string {
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if needsEscape(r, size, jsonEscaped) {
+			break
+		}
+		i += size
+	}
+	if i == len(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	b.WriteString(s[:i])
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case !needsEscape(r, size, jsonEscaped):
+			b.WriteString(s[i : i+size])
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == utf8.RuneError:
+			b.WriteString(`\x`)
+			b.WriteString(strconv.FormatUint(uint64(s[i])>>4, 16))
+			b.WriteString(strconv.FormatUint(uint64(s[i])&0xf, 16))
+		default:
+			q := strconv.QuoteRuneToASCII(r)
+			b.WriteString(q[1 : len(q)-1])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// needsEscape reports whether the rune r of size bytes can alter how a
+// line is displayed
+func needsEscape(r rune, size int, jsonEscaped bool) bool {
+	switch {
+	case r >= 0x7f && r <= 0x9f:
+		return true
+	case r >= 0x202a && r <= 0x202e, r >= 0x2066 && r <= 0x2069:
+		return true
+	case jsonEscaped:
+		return false
+	case r < 0x20:
+		return true
+	}
+	return r == utf8.RuneError && size == 1
+}
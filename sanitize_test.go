@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSanitizeConsole(t *testing.T) {
+	config := DefaultConfig(Development)
+	config.Sanitize = &SanitizeConfig{}
+	read := initializeToFile(t, config)
+
+	input := "alice\n2024-01-15T10:30:45.000Z\tINFO\tadmin logged in \x1b[2J"
+	Infof("user %s logged in", input)
+	With(zap.String("tenant", "acme\u202eevil")).Info("with", zap.String("user", input), zap.ByteString("raw", []byte("a\u009bb")))
+
+	lines := read()
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	if want := `user alice\n2024-01-15T10:30:45.000Z\tINFO\tadmin logged in \x1b[2J logged in`; !strings.Contains(lines[0], want) {
+		t.Errorf("message line = %q, want it to contain %q", lines[0], want)
+	}
+	for _, want := range []string{`"tenant": "acme\\u202eevil"`, `"user": "alice\n2024`, `\u001b[2J"`, `"raw": "a\\u009bb"`} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("field line = %q, want it to contain %q", lines[1], want)
+		}
+	}
+	if strings.ContainsAny(strings.Join(lines, ""), "\x1b\u202e\u009b") {
+		t.Error("output contains unescaped control characters")
+	}
+}
+
+func TestSanitizeTruncates(t *testing.T) {
+	config := DefaultConfig(Staging)
+	config.Sanitize = &SanitizeConfig{MaxMessageLength: 10, MaxFieldLength: 2}
+	read := initializeToFile(t, config)
+
+	Info(strings.Repeat("m", 25), zap.String("name", "héllo"), zap.Int("n", 12345))
+
+	entries := decodeEntries(t, read())
+	if got := entries[0]["msg"]; got != "mmmmmmmmmm…(truncated 15B)" {
+		t.Errorf("msg = %q", got)
+	}
+	// The cut does not split the two byte é
+	if got := entries[0]["name"]; got != "h…(truncated 5B)" {
+		t.Errorf("name = %q", got)
+	}
+	if got := entries[0]["n"]; got != float64(12345) {
+		t.Errorf("n = %v", got)
+	}
+}
+
+func TestEscapeControl(t *testing.T) {
+	tests := []struct {
+		in          string
+		jsonEscaped bool
+		want        string
+	}{
+		{"plain text ✓", false, "plain text ✓"},
+		{"a\r\nb\tc", false, `a\r\nb\tc`},
+		{"\x1b[31mred\x7f", false, `\x1b[31mred\x7f`},
+		{"bad \xff byte", false, `bad \xff byte`},
+		{"rtl \u202e \u2066", false, `rtl \u202e \u2066`},
+		{"a\nb\u0085", true, "a\nb\\u0085"},
+	}
+	for _, tt := range tests {
+		if got := escapeControl(tt.in, tt.jsonEscaped); got != tt.want {
+			t.Errorf("escapeControl(%q, %v) = %q, want %q", tt.in, tt.jsonEscaped, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Defaults for request-scoped buffering
+const (
+	DefaultScopeMaxEntries = 100
+	DefaultScopeLevel      = zapcore.DebugLevel
+	DefaultScopeTrigger    = zapcore.ErrorLevel
+)
+
+// ScopeOption configures a Scope
+type ScopeOption func(*Scope)
+
+// WithScopeLevel sets the lowest level that is buffered. Entries below it
+// are discarded as usual. Defaults to DefaultScopeLevel.
+func WithScopeLevel(level zapcore.Level) ScopeOption {
+	return func(s *Scope) {
+		s.level = level
+	}
+}
+
+// WithScopeTrigger sets the level at which buffered entries are written.
+// Defaults to DefaultScopeTrigger.
+func WithScopeTrigger(level zapcore.Level) ScopeOption {
+	return func(s *Scope) {
+		s.trigger = level
+	}
+}
+
+// WithScopeMaxEntries bounds the number of buffered entries; when the buffer
+// is full the oldest entry is dropped. Defaults to DefaultScopeMaxEntries.
+func WithScopeMaxEntries(n int) ScopeOption {
+	return func(s *Scope) {
+		if n > 0 {
+			s.maxEntries = n
+		}
+	}
+}
+
+// Scope buffers entries that are below the logger's level, such as Debug
+// and Info in production, and writes them only if an entry at the trigger
+// level is logged within the scope. Otherwise they are discarded by End.
+// Buffered entries keep a copy of their fields, taken when they are logged.
+type Scope struct {
+	level      zapcore.Level
+	trigger    zapcore.Level
+	maxEntries int
+
+	mu        sync.Mutex
+	entries   []scopeEntry
+	dropped   int
+	triggered bool
+	ended     bool
+}
+
+// scopeEntry is a buffered entry and the core it was logged to
+type scopeEntry struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// NewScope returns a copy of ctx whose logger, as used by FromContext and
+// the Context functions, buffers entries in a new Scope. The package level
+// functions such as Debug are not affected. Call End when the scope is
+// done, typically with defer.
+func NewScope(ctx context.Context, opts ...ScopeOption) (context.Context, *Scope) {
+	s := &Scope{
+		level:      DefaultScopeLevel,
+		trigger:    DefaultScopeTrigger,
+		maxEntries: DefaultScopeMaxEntries,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if base := contextLogger(ctx); base != nil {
+		ctx = NewContext(ctx, base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &scopeCore{Core: core, scope: s}
+		})))
+	}
+	return ctx, s
+}
+
+// Flush writes the buffered entries. Entries logged in the scope afterwards
+// are written immediately.
+func (s *Scope) Flush() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	entries, dropped := s.entries, s.dropped
+	s.entries, s.dropped = nil, 0
+	s.triggered = true
+	s.mu.Unlock()
+
+	if dropped > 0 && len(entries) > 0 {
+		first := entries[0]
+		ent := zapcore.Entry{
+			Level:      first.ent.Level,
+			Time:       time.Now(),
+			LoggerName: first.ent.LoggerName,
+			Message:    "scope buffer full, earlier entries dropped",
+		}
+		_ = first.core.Write(ent, []zapcore.Field{zap.Int("dropped", dropped)})
+	}
+	for _, e := range entries {
+		_ = e.core.Write(e.ent, e.fields)
+	}
+}
+
+// End discards the buffered entries. Entries below the logger's level that
+// are logged in the scope afterwards are discarded as well.
+func (s *Scope) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries, s.dropped = nil, 0
+	s.ended = true
+}
+
+// add buffers an entry, or writes it if the scope has been triggered
+func (s *Scope) add(core zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) error {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.triggered {
+		s.mu.Unlock()
+		return core.Write(ent, fields)
+	}
+	defer s.mu.Unlock()
+
+	if len(s.entries) >= s.maxEntries {
+		s.entries = append(s.entries[:0], s.entries[1:]...)
+		s.dropped++
+	}
+	// The caller may change the values the fields refer to once Write
+	// returns, so they are copied and lazy values computed now
+	s.entries = append(s.entries, scopeEntry{
+		core:   core,
+		ent:    ent,
+		fields: snapshotFields(fields),
+	})
+	return nil
+}
+
+// scopeCore passes enabled entries to the wrapped core and buffers the
+// disabled ones in its scope. The wrapped core writes entries regardless of
+// their level, as levelCore and zap's own cores do.
+type scopeCore struct {
+	zapcore.Core
+	scope *Scope
+}
+
+// Enabled implements zapcore.Core
+func (c *scopeCore) Enabled(l zapcore.Level) bool {
+	return c.Core.Enabled(l) || levelAtLeast(l, c.scope.level)
+}
+
+// With implements zapcore.Core
+func (c *scopeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &scopeCore{Core: c.Core.With(fields), scope: c.scope}
+}
+
+// Check implements zapcore.Core
+func (c *scopeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if levelAtLeast(ent.Level, c.scope.trigger) {
+		c.scope.Flush()
+	}
+	if outputCore(c.Core).Enabled(ent.Level) || !levelAtLeast(ent.Level, c.scope.level) {
+		return c.Core.Check(ent, ce)
+	}
+	// The wrapped core may still record the entry in the recent buffer
+	return c.Core.Check(ent, ce).AddCore(ent, c)
+}
+
+// Write implements zapcore.Core. It is only called for entries that the
+// wrapped core does not write to the outputs.
+func (c *scopeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.scope.add(outputCore(c.Core), ent, fields)
+}
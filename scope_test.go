@@ -0,0 +1,165 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestScopeFlushOnError(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Production))
+
+	ctx, scope := NewScope(context.Background())
+	defer scope.End()
+	l := FromContext(ctx).With(zap.String("component", "db"))
+
+	DebugContext(ctx, "first")
+	l.Info("second", zap.Int("n", 2))
+	FromContext(ctx).Log(TraceLevel, "below scope level")
+	WarnContext(ctx, "enabled")
+	ErrorContext(ctx, "failed")
+	DebugContext(ctx, "after trigger")
+
+	entries := decodeEntries(t, read())
+	want := []string{"enabled", "first", "second", "failed", "after trigger"}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %v", len(entries), len(want), entries)
+	}
+	for i, msg := range want {
+		if entries[i]["msg"] != msg {
+			t.Errorf("entry %d msg = %v, want %v", i, entries[i]["msg"], msg)
+		}
+	}
+	if entries[2]["level"] != "info" || entries[2]["component"] != "db" || entries[2]["n"] != float64(2) {
+		t.Errorf("buffered entry = %v", entries[2])
+	}
+}
+
+func TestScopeDiscardOnEnd(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Production))
+
+	ctx, scope := NewScope(context.Background())
+	DebugContext(ctx, "discarded")
+	InfoContext(ctx, "discarded")
+	scope.End()
+	ErrorContext(ctx, "after end")
+	DebugContext(ctx, "after end")
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 || entries[0]["msg"] != "after end" || entries[0]["level"] != "error" {
+		t.Errorf("entries = %v, want only the error entry", entries)
+	}
+}
+
+func TestScopeWithLimitsAndRecentBuffer(t *testing.T) {
+	config := DefaultConfig(Production)
+	config.Limits = &Limits{MaxStringLength: 8}
+	config.RecentBuffer = 10
+	read := initializeToFile(t, config)
+
+	ctx, scope := NewScope(context.Background())
+	defer scope.End()
+	DebugContext(ctx, "buffered", zap.String("body", "0123456789"))
+	ErrorContext(ctx, "failed")
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 2 || entries[0]["msg"] != "buffered" || entries[1]["msg"] != "failed" {
+		t.Fatalf("entries = %v, want the buffered entry and the error", entries)
+	}
+	if body, _ := entries[0]["body"].(string); !strings.HasPrefix(body, "01234567…") {
+		t.Errorf("buffered body = %q, want it truncated", body)
+	}
+	if got := len(recent.snapshot()); got != 2 {
+		t.Errorf("recent buffer holds %d entries, want 2", got)
+	}
+}
+
+func TestScopeCopiesFields(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Production))
+
+	ctx, scope := NewScope(context.Background())
+	defer scope.End()
+	roles := []string{"admin"}
+	d := &diff{changed: 1}
+	DebugContext(ctx, "buffered", zap.Strings("roles", roles), zap.Object("diff", d),
+		Lazy("lazy", func() interface{} { return d.changed }))
+	roles[0], d.changed = "guest", 2
+	ErrorContext(ctx, "failed")
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v, want the buffered entry and the error", entries)
+	}
+	buffered := entries[0]
+	if roles, _ := buffered["roles"].([]interface{}); len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("roles = %v, want [admin]", buffered["roles"])
+	}
+	if d, _ := buffered["diff"].(map[string]interface{}); d["changed"] != float64(1) {
+		t.Errorf("diff = %v, want changed 1", buffered["diff"])
+	}
+	if buffered["lazy"] != float64(1) {
+		t.Errorf("lazy = %v, want 1", buffered["lazy"])
+	}
+}
+
+func TestScopeOptions(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Production))
+
+	ctx, scope := NewScope(context.Background(),
+		WithScopeLevel(zapcore.InfoLevel),
+		WithScopeTrigger(zapcore.WarnLevel),
+		WithScopeMaxEntries(2),
+	)
+	defer scope.End()
+
+	DebugContext(ctx, "not buffered")
+	for _, msg := range []string{"one", "two", "three"} {
+		InfoContext(ctx, msg)
+	}
+	WarnContext(ctx, "trigger")
+
+	entries := decodeEntries(t, read())
+	want := []string{"scope buffer full, earlier entries dropped", "two", "three", "trigger"}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %v", len(entries), len(want), entries)
+	}
+	for i, msg := range want {
+		if entries[i]["msg"] != msg {
+			t.Errorf("entry %d msg = %v, want %v", i, entries[i]["msg"], msg)
+		}
+	}
+	if entries[0]["dropped"] != float64(1) {
+		t.Errorf("dropped = %v, want 1", entries[0]["dropped"])
+	}
+}
+
+func TestScopedMiddleware(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Production))
+
+	handler := ScopedMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		DebugContext(r.Context(), "loading")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	for _, path := range []string{"/ok", "/fail"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %v", len(entries), entries)
+	}
+	if entries[0]["msg"] != "loading" || entries[1]["msg"] != "request completed" {
+		t.Errorf("entries = %v, want the buffered entry of the failed request first", entries)
+	}
+	if entries[0][RequestIDKey] == nil || entries[0][RequestIDKey] != entries[1][RequestIDKey] {
+		t.Errorf("request_id = %v and %v, want the same ID", entries[0][RequestIDKey], entries[1][RequestIDKey])
+	}
+}
@@ -0,0 +1,292 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Defaults for SentryConfig
+const (
+	DefaultSentryQueueSize = 100
+	DefaultSentryTimeout   = 10 * time.Second
+)
+
+// sentryClient identifies this package to Sentry
+const sentryClient = "kingrain94-logger/1.0"
+
+// SentryConfig configures forwarding new error fingerprints, see
+// ErrorSummary, to a Sentry-compatible envelope endpoint. Only the first
+// entry of each fingerprint is sent; failures are reported as internal
+// errors.
+type SentryConfig struct {
+	// DSN is the project's client key URL,
+	// e.g. "https://public@o0.ingest.sentry.io/42"
+	DSN string
+	// Release identifies the application version, if set
+	Release string
+	// ServerName identifies the host, if set
+	ServerName string
+
+	// QueueSize bounds the events waiting to be sent; events for new
+	// fingerprints found while the queue is full are dropped
+	QueueSize int
+	// Timeout bounds each request
+	Timeout time.Duration
+	// Client is the HTTP client used for requests
+	Client *http.Client
+}
+
+// withDefaults returns a copy of c with zero values replaced by defaults
+func (c SentryConfig) withDefaults() SentryConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultSentryQueueSize
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultSentryTimeout
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{}
+	}
+	return c
+}
+
+// sentry is the forwarder of the current logger, or nil. It is read by the
+// error aggregation hook, which runs without locking, so it is not
+// protected by mu.
+var sentry atomic.Pointer[sentryForwarder]
+
+// sentryForwarder sends events for new fingerprints from a background
+// goroutine
+type sentryForwarder struct {
+	config      SentryConfig
+	environment string
+	endpoint    string
+	auth        string
+	errorOutput zapcore.WriteSyncer
+
+	queue   chan *ErrorGroup
+	stop    chan struct{}
+	stopped chan struct{}
+	close   sync.Once
+}
+
+func newSentryForwarder(config SentryConfig, environment string, errorOutput zapcore.WriteSyncer) (*sentryForwarder, error) {
+	endpoint, publicKey, err := parseSentryDSN(config.DSN)
+	if err != nil {
+		return nil, err
+	}
+	config = config.withDefaults()
+
+	f := &sentryForwarder{
+		config:      config,
+		environment: environment,
+		endpoint:    endpoint,
+		auth: fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s",
+			sentryClient, publicKey),
+		errorOutput: errorOutput,
+		queue:       make(chan *ErrorGroup, config.QueueSize),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go f.run()
+	return f, nil
+}
+
+// parseSentryDSN returns the envelope endpoint and public key of a DSN of
+// the form scheme://public_key@host[:port][/path]/project_id
+func parseSentryDSN(dsn string) (endpoint, publicKey string, err error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("sentry: invalid DSN: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return "", "", errors.New("sentry: DSN has no public key")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	projectID := path[i+1:]
+	if i < 0 || projectID == "" || u.Host == "" {
+		return "", "", errors.New("sentry: DSN has no host or project ID")
+	}
+	endpoint = fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:i], projectID)
+	return endpoint, u.User.Username(), nil
+}
+
+// enqueue hands a new group to the background goroutine without blocking
+func (f *sentryForwarder) enqueue(group *ErrorGroup) {
+	select {
+	case <-f.stop:
+	case f.queue <- group:
+	default:
+		f.reportError(fmt.Errorf("queue full, dropped event for fingerprint %s", group.Fingerprint))
+	}
+}
+
+// shutdown sends the queued events and stops the forwarder
+func (f *sentryForwarder) shutdown() {
+	f.close.Do(func() {
+		close(f.stop)
+		<-f.stopped
+	})
+}
+
+func (f *sentryForwarder) run() {
+	defer close(f.stopped)
+	for {
+		select {
+		case group := <-f.queue:
+			f.send(group)
+		case <-f.stop:
+			for {
+				select {
+				case group := <-f.queue:
+					f.send(group)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts one event, reporting failures
+func (f *sentryForwarder) send(group *ErrorGroup) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, bytes.NewReader(f.envelope(group)))
+	if err != nil {
+		f.reportError(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", f.auth)
+
+	resp, err := f.config.Client.Do(req)
+	if err != nil {
+		f.reportError(err)
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		f.reportError(fmt.Errorf("server responded %s", resp.Status))
+	}
+}
+
+// sentryFrame is a stack frame in the Sentry event format
+type sentryFrame struct {
+	Function string `json:"function,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Lineno   int    `json:"lineno,omitempty"`
+}
+
+// envelope encodes a group's sample entry as a Sentry envelope holding one
+// event
+func (f *sentryForwarder) envelope(group *ErrorGroup) []byte {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	eventID := hex.EncodeToString(id[:])
+	sample := group.Sample
+
+	exceptionType := group.ErrorType
+	if exceptionType == "" {
+		exceptionType = group.Message
+	}
+	exceptionValue := sample.Error
+	if exceptionValue == "" {
+		exceptionValue = sample.Message
+	}
+	exception := map[string]interface{}{"type": exceptionType, "value": exceptionValue}
+	if frames := sentryFrames(sample.Stack); len(frames) > 0 {
+		exception["stacktrace"] = map[string]interface{}{"frames": frames}
+	}
+
+	level := sample.Level
+	switch level {
+	case "critical", "dpanic", "panic":
+		level = "fatal"
+	}
+
+	event := map[string]interface{}{
+		"event_id":    eventID,
+		"timestamp":   sample.Time.UTC().Format(time.RFC3339Nano),
+		"platform":    "go",
+		"level":       level,
+		"logger":      sample.Logger,
+		"message":     map[string]interface{}{"formatted": sample.Message},
+		"fingerprint": []string{group.Fingerprint},
+		"exception":   map[string]interface{}{"values": []interface{}{exception}},
+		"environment": f.environment,
+		"extra":       sample.Fields,
+	}
+	if f.config.Release != "" {
+		event["release"] = f.config.Release
+	}
+	if f.config.ServerName != "" {
+		event["server_name"] = f.config.ServerName
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		// Fields that cannot be encoded are left out rather than
+		// losing the event
+		delete(event, "extra")
+		payload, _ = json.Marshal(event)
+	}
+
+	var b bytes.Buffer
+	header, _ := json.Marshal(map[string]string{
+		"event_id": eventID,
+		"dsn":      f.config.DSN,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	b.Write(header)
+	b.WriteString("\n")
+	b.WriteString(`{"type":"event","length":` + strconv.Itoa(len(payload)) + "}\n")
+	b.Write(payload)
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// sentryFrames converts a zap stack trace to Sentry frames, which are
+// ordered from the outermost call to the innermost
+func sentryFrames(stack string) []sentryFrame {
+	lines := strings.Split(stack, "\n")
+	var frames []sentryFrame
+	for i := 0; i+1 < len(lines); i += 2 {
+		frame := sentryFrame{Function: lines[i]}
+		location := strings.TrimSpace(lines[i+1])
+		if j := strings.LastIndex(location, ":"); j > 0 {
+			frame.Filename = location[:j]
+			frame.Lineno, _ = strconv.Atoi(location[j+1:])
+		}
+		frames = append(frames, frame)
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// reportError writes a forwarding failure to the logger's error output,
+// which counts it as an internal error
+func (f *sentryForwarder) reportError(err error) {
+	fmt.Fprintf(f.errorOutput, "%v sentry error: %v\n", time.Now().UTC(), err)
+	_ = f.errorOutput.Sync()
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestParseSentryDSN(t *testing.T) {
+	tests := []struct {
+		dsn          string
+		wantEndpoint string
+		wantKey      string
+		wantErr      bool
+	}{
+		{"https://public@o0.ingest.sentry.io/42", "https://o0.ingest.sentry.io/api/42/envelope/", "public", false},
+		{"http://key@localhost:9000/sentry/7", "http://localhost:9000/sentry/api/7/envelope/", "key", false},
+		{"https://o0.ingest.sentry.io/42", "", "", true},
+		{"https://public@o0.ingest.sentry.io/", "", "", true},
+		{"::", "", "", true},
+	}
+	for _, tt := range tests {
+		endpoint, key, err := parseSentryDSN(tt.dsn)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSentryDSN(%q) error = nil, want error", tt.dsn)
+			}
+			continue
+		}
+		if err != nil || endpoint != tt.wantEndpoint || key != tt.wantKey {
+			t.Errorf("parseSentryDSN(%q) = %q, %q, %v", tt.dsn, endpoint, key, err)
+		}
+	}
+}
+
+func TestSentryForwardsNewFingerprints(t *testing.T) {
+	type request struct {
+		path, auth string
+		body       []byte
+	}
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.URL.Path, r.Header.Get("X-Sentry-Auth"), body}
+	}))
+	defer server.Close()
+
+	config := DefaultConfig(Staging)
+	config.Sentry = &SentryConfig{
+		DSN:     strings.Replace(server.URL, "://", "://public@", 1) + "/42",
+		Release: "v1.2.3",
+	}
+	initializeToFile(t, config)
+	ResetErrorSummary()
+	t.Cleanup(ResetErrorSummary)
+
+	for i := 0; i < 3; i++ {
+		Error("payment failed", zap.Error(errors.New("card declined")), zap.String("order_id", "A1"))
+	}
+
+	var req request
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+	select {
+	case <-requests:
+		t.Error("repeated fingerprint was forwarded again")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if req.path != "/api/42/envelope/" {
+		t.Errorf("path = %q", req.path)
+	}
+	if !strings.Contains(req.auth, "sentry_key=public") {
+		t.Errorf("X-Sentry-Auth = %q", req.auth)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(req.body))
+	var items []map[string]interface{}
+	for scanner.Scan() {
+		var item map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("envelope line %q: %v", scanner.Text(), err)
+		}
+		items = append(items, item)
+	}
+	if len(items) != 3 || items[1]["type"] != "event" {
+		t.Fatalf("envelope = %s", req.body)
+	}
+
+	header, event := items[0], items[2]
+	if header["event_id"] != event["event_id"] {
+		t.Errorf("header event_id = %v, event event_id = %v", header["event_id"], event["event_id"])
+	}
+	if event["level"] != "error" || event["release"] != "v1.2.3" || event["environment"] != "staging" {
+		t.Errorf("event = %v", event)
+	}
+	fingerprint, _ := event["fingerprint"].([]interface{})
+	if summary := ErrorSummary(); len(summary) != 1 || len(fingerprint) != 1 || fingerprint[0] != summary[0].Fingerprint {
+		t.Errorf("fingerprint = %v, want the error group's", fingerprint)
+	}
+	exception := event["exception"].(map[string]interface{})["values"].([]interface{})[0].(map[string]interface{})
+	if exception["type"] != "*errors.errorString" || exception["value"] != "card declined" {
+		t.Errorf("exception = %v", exception)
+	}
+	if extra, _ := event["extra"].(map[string]interface{}); extra["order_id"] != "A1" {
+		t.Errorf("extra = %v", event["extra"])
+	}
+}
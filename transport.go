@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Transport wraps an http.RoundTripper, http.DefaultTransport if nil, so
+// that outbound requests carry the trace context and correlation ID of the
+// request context and are logged, without the caller, when they complete.
+//
+// A traceparent header naming a new child span is added unless the request
+// already has one, e.g. from OpenTelemetry instrumentation.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx := req.Context()
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(ctx)
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("url", redactedURL(req)),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && req.Header.Get(TraceparentHeader) == "" {
+		child := sc.WithSpanID(newSpanID()).WithRemote(false)
+		req.Header.Set(TraceparentHeader, FormatTraceparent(child))
+		fields = append(fields, zap.String("child_span_id", child.SpanID().String()))
+	}
+	if id := RequestIDFromContext(ctx); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+
+	fields = append(fields, zap.Duration("duration", time.Since(start)))
+	if err != nil {
+		logContext(ctx, zapcore.ErrorLevel, "outbound request failed", append(fields, zap.Error(err)), false)
+		return resp, err
+	}
+	fields = append(fields, zap.Int("status", resp.StatusCode))
+	logContext(ctx, levelForStatus(resp.StatusCode), "outbound request completed", fields, false)
+	return resp, nil
+}
+
+// redactedURL returns the request URL without user info and query, which
+// commonly carry credentials
+func redactedURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	return u.String()
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	var outbound http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header.Clone()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer downstream.Close()
+
+	client := &http.Client{Transport: Transport(nil)}
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, downstream.URL+"/inventory?token=secret", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("client.Do() error = %v", err)
+			return
+		}
+		resp.Body.Close()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := outbound.Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("outbound %s = %q, want req-1", RequestIDHeader, got)
+	}
+	sc, err := ParseTraceparent(outbound.Get(TraceparentHeader))
+	if err != nil {
+		t.Fatalf("outbound traceparent is invalid: %v", err)
+	}
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("outbound trace ID = %s, want the incoming trace ID", sc.TraceID())
+	}
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	call := entries[0]
+	if call["msg"] != "outbound request completed" || call["level"] != "error" || call["status"] != float64(http.StatusServiceUnavailable) {
+		t.Errorf("outbound entry = %v", call)
+	}
+	if call["url"] != downstream.URL+"/inventory" {
+		t.Errorf("outbound url = %v, want it without the query", call["url"])
+	}
+	if call["child_span_id"] != sc.SpanID().String() || call[RequestIDKey] != "req-1" {
+		t.Errorf("outbound entry = %v", call)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestTransportError(t *testing.T) {
+	read := initializeToFile(t, DefaultConfig(Staging))
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.invalid/", nil)
+	if _, err := Transport(failingTransport{}).RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() error = nil, want error")
+	}
+	if req.Header.Get(TraceparentHeader) != "" {
+		t.Error("RoundTrip() modified the caller's request")
+	}
+
+	entries := decodeEntries(t, read())
+	if len(entries) != 1 || entries[0]["msg"] != "outbound request failed" || entries[0]["error"] != "connection refused" {
+		t.Fatalf("entries = %v", entries)
+	}
+	if caller, ok := entries[0]["caller"]; ok {
+		t.Errorf("caller = %v, want none", caller)
+	}
+}